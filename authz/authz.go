@@ -0,0 +1,178 @@
+// Package authz decides what a user may do. Roles are named sets of
+// permissions, and the services ask an Authorizer instead of comparing role
+// names themselves.
+package authz
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var ErrForbidden = errors.New("forbidden")
+
+type Permission string
+
+// A permission on its own covers the subject's own records. The ":any" form
+// extends it to everyone's in the subject's organization.
+const (
+	ListsRead     Permission = "lists:read"
+	ListsWrite    Permission = "lists:write"
+	ItemsWrite    Permission = "items:write"
+	ItemsComplete Permission = "items:complete"
+
+	ListsReadAny     Permission = "lists:read:any"
+	ListsWriteAny    Permission = "lists:write:any"
+	ItemsWriteAny    Permission = "items:write:any"
+	ItemsCompleteAny Permission = "items:complete:any"
+
+	APIKeysManage    Permission = "api_keys:manage"
+	APIKeysManageAny Permission = "api_keys:manage:any"
+
+	UsersManage    Permission = "users:manage"
+	BackupsManage  Permission = "backups:manage"
+	RetentionPurge Permission = "retention:purge"
+
+	// UsersImpersonate allows acting as another user for a short while;
+	// AuditRead allows reading the record of what was done that way.
+	UsersImpersonate Permission = "users:impersonate"
+	AuditRead        Permission = "audit:read"
+
+	// MFAEnroll allows setting up a second factor, which is then asked
+	// for on every login.
+	MFAEnroll Permission = "mfa:enroll"
+
+	// OrgsManage allows creating organizations and managing the accounts
+	// of all of them. Every other permission stops at the subject's own
+	// organization, and none reaches the lists of another.
+	OrgsManage Permission = "orgs:manage"
+)
+
+// Permissions lists every known permission.
+var Permissions = []Permission{
+	ListsRead, ListsWrite, ItemsWrite, ItemsComplete,
+	ListsReadAny, ListsWriteAny, ItemsWriteAny, ItemsCompleteAny,
+	APIKeysManage, APIKeysManageAny, UsersManage, BackupsManage, RetentionPurge,
+	MFAEnroll, UsersImpersonate, AuditRead, OrgsManage,
+}
+
+// DefaultRoles reproduce the built-in behaviour: admins run their
+// organization, superadmins run the whole server, and users may work with
+// their own lists and items.
+var DefaultRoles = map[string][]string{
+	"superadmin": {"*"},
+	"admin":      {"lists:*", "items:*", "api_keys:*", "users:*", "audit:read", "mfa:enroll"},
+	"user":       {"lists:read", "lists:write", "items:write", "items:complete", "api_keys:manage"},
+}
+
+// Subject is who a request acts as, and in which organization.
+type Subject struct {
+	UserID int
+	OrgID  int
+	Role   string
+}
+
+// Authorizer maps roles to permissions.
+type Authorizer struct {
+	roles map[string]map[Permission]bool
+}
+
+// New builds an Authorizer from role definitions. A grant is a permission
+// name, "*" for every permission, or a prefix such as "lists:*", which also
+// includes the ":any" forms. Grants that match no known permission are
+// rejected so typos do not go unnoticed.
+func New(roles map[string][]string) (*Authorizer, error) {
+	a := &Authorizer{roles: make(map[string]map[Permission]bool, len(roles))}
+	for role, grants := range roles {
+		perms := make(map[Permission]bool)
+		for _, grant := range grants {
+			matched := false
+			for _, perm := range Permissions {
+				if grantMatches(grant, perm) {
+					perms[perm] = true
+					matched = true
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("role %q: unknown permission %q", role, grant)
+			}
+		}
+		a.roles[role] = perms
+	}
+	return a, nil
+}
+
+// LoadRoles reads role definitions from a JSON file mapping role names to
+// lists of grants. An empty path returns DefaultRoles.
+func LoadRoles(path string) (map[string][]string, error) {
+	if path == "" {
+		return DefaultRoles, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read roles file: %w", err)
+	}
+	var roles map[string][]string
+	if err := json.Unmarshal(data, &roles); err != nil {
+		return nil, fmt.Errorf("failed to decode roles file: %w", err)
+	}
+	return roles, nil
+}
+
+func grantMatches(grant string, perm Permission) bool {
+	if grant == "*" || grant == string(perm) {
+		return true
+	}
+	prefix, wildcard := strings.CutSuffix(grant, "*")
+	return wildcard && strings.HasPrefix(string(perm), prefix)
+}
+
+// HasRole reports whether the role is defined.
+func (a *Authorizer) HasRole(role string) bool {
+	_, ok := a.roles[role]
+	return ok
+}
+
+// Can reports whether sub holds perm.
+func (a *Authorizer) Can(sub Subject, perm Permission) bool {
+	return a.roles[sub.Role][perm]
+}
+
+// Covers reports whether sub holds every permission of role. Giving the
+// role to someone, or managing an account that has it, then gains sub
+// nothing it could not already do.
+func (a *Authorizer) Covers(sub Subject, role string) bool {
+	for perm := range a.roles[role] {
+		if !a.Can(sub, perm) {
+			return false
+		}
+	}
+	return true
+}
+
+// CanOn reports whether sub may use perm on a record owned by ownerID:
+// either it is sub's own record, or sub holds the ":any" form of perm.
+func (a *Authorizer) CanOn(sub Subject, perm Permission, ownerID int) bool {
+	if ownerID == sub.UserID && a.Can(sub, perm) {
+		return true
+	}
+	return a.Can(sub, perm+":any")
+}
+
+// Authorize is Can returning ErrForbidden instead of false.
+func (a *Authorizer) Authorize(sub Subject, perm Permission) error {
+	if !a.Can(sub, perm) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// AuthorizeOn is CanOn returning ErrForbidden instead of false.
+func (a *Authorizer) AuthorizeOn(sub Subject, perm Permission, ownerID int) error {
+	if !a.CanOn(sub, perm, ownerID) {
+		return ErrForbidden
+	}
+	return nil
+}
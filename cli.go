@@ -0,0 +1,249 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/YahyaCengiz/todo-v2/authz"
+	"github.com/YahyaCengiz/todo-v2/config"
+	"github.com/YahyaCengiz/todo-v2/oidc"
+	"github.com/YahyaCengiz/todo-v2/store"
+)
+
+const usage = `usage:
+  todo-v2 [flags]                       start the API server
+  todo-v2 migrate status [flags]        print the SQLite schema version
+  todo-v2 migrate up [flags]            migrate the SQLite schema to the latest version
+  todo-v2 migrate down <version> [flags] revert the SQLite schema to <version>
+  todo-v2 snapshot list [flags]         list JSON store snapshots
+  todo-v2 snapshot create [flags]       take a JSON store snapshot
+  todo-v2 snapshot restore <name> [flags]
+                                        restore a JSON store snapshot
+  todo-v2 snapshot restore-at <RFC3339 time> [flags]
+                                        restore the JSON store as it was at a time
+  todo-v2 set-role <username> <role> [flags]
+                                        give a user a role, e.g. the first superadmin
+  todo-v2 mock-idp [flags]              run a mock OpenID Connect provider for trying SSO;
+                                        -user name[:group,...] may be repeated
+
+snapshot and set-role open the JSON store themselves and refuse to run while
+a server has it open; stop the server first or use the admin API.`
+
+func runCommand(args []string) error {
+	switch args[0] {
+	case "migrate":
+		return runMigrate(args[1:])
+	case "snapshot":
+		return runSnapshot(args[1:])
+	case "set-role":
+		return runSetRole(args[1:])
+	case "mock-idp":
+		return runMockIdP(args[1:])
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], usage)
+	}
+}
+
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	action, args := args[0], args[1:]
+	if action != "status" && action != "up" && action != "down" {
+		return fmt.Errorf("unknown migrate action %q\n%s", action, usage)
+	}
+
+	target := store.LatestSchemaVersion()
+	if action == "down" {
+		if len(args) == 0 {
+			return errors.New("migrate down requires a target version")
+		}
+		v, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[0])
+		}
+		target, args = v, args[1:]
+	}
+
+	cfg, err := config.Load(args)
+	if err != nil {
+		return err
+	}
+	db, err := store.OpenSQLiteStore(cfg.SQLitePath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if action != "status" {
+		if err := db.MigrateTo(target); err != nil {
+			return err
+		}
+	}
+
+	version, err := db.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("schema version: %d (latest %d)\n", version, store.LatestSchemaVersion())
+	return nil
+}
+
+// runSetRole changes a role without an admin token, which is the only way
+// to create the first superadmin.
+func runSetRole(args []string) error {
+	if len(args) < 2 {
+		return errors.New("set-role requires a username and a role")
+	}
+	username, role, args := args[0], args[1], args[2:]
+
+	cfg, err := config.Load(args)
+	if err != nil {
+		return err
+	}
+	roles, err := authz.LoadRoles(cfg.RolesFile)
+	if err != nil {
+		return err
+	}
+	authorizer, err := authz.New(roles)
+	if err != nil {
+		return err
+	}
+	if !authorizer.HasRole(role) {
+		return fmt.Errorf("unknown role %q", role)
+	}
+
+	repo, err := openStore(cfg)
+	if err != nil {
+		return storeInUse(err)
+	}
+	if closer, ok := repo.(io.Closer); ok {
+		defer closer.Close()
+	}
+	user, err := repo.GetUserByUsername(username)
+	if err != nil {
+		return err
+	}
+	user.Role = role
+	if err := repo.UpdateUser(*user); err != nil {
+		return err
+	}
+	fmt.Printf("%s (organization %d) is now %s\n", user.Username, user.OrgID, role)
+	return nil
+}
+
+func runSnapshot(args []string) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	action, args := args[0], args[1:]
+
+	var target string
+	switch action {
+	case "list", "create":
+	case "restore", "restore-at":
+		if len(args) == 0 {
+			return fmt.Errorf("snapshot %s requires an argument", action)
+		}
+		target, args = args[0], args[1:]
+	default:
+		return fmt.Errorf("unknown snapshot action %q\n%s", action, usage)
+	}
+
+	cfg, err := config.Load(args)
+	if err != nil {
+		return err
+	}
+	if cfg.BackupDir == "" {
+		return store.ErrBackupsDisabled
+	}
+	s, err := openFileStore(cfg)
+	if err != nil {
+		return storeInUse(err)
+	}
+	defer s.Close()
+
+	var snapshot *store.SnapshotInfo
+	switch action {
+	case "list":
+		snapshots, err := s.ListSnapshots()
+		if err != nil {
+			return err
+		}
+		for _, info := range snapshots {
+			fmt.Printf("%s\t%s\tseq=%d\t%d bytes\n", info.Name, info.CreatedAt.Format(time.RFC3339), info.Seq, info.Size)
+		}
+		return nil
+	case "create":
+		snapshot, err = s.Snapshot()
+	case "restore":
+		snapshot, err = s.RestoreSnapshot(target)
+	case "restore-at":
+		at, perr := time.Parse(time.RFC3339, target)
+		if perr != nil {
+			return fmt.Errorf("invalid time %q, expected RFC 3339", target)
+		}
+		snapshot, err = s.RestoreToTime(at)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s: %s (seq=%d)\n", action, snapshot.Name, snapshot.Seq)
+	return nil
+}
+
+// storeInUse points at the way out when the server holds the store.
+func storeInUse(err error) error {
+	if errors.Is(err, store.ErrStoreLocked) {
+		return fmt.Errorf("%w; stop the server first or use the admin API", err)
+	}
+	return err
+}
+
+// runMockIdP serves oidc.MockProvider. Its users log in without a
+// password, so it is only for development.
+func runMockIdP(args []string) error {
+	var users []oidc.MockUser
+	fs := flag.NewFlagSet("mock-idp", flag.ContinueOnError)
+	addr := fs.String("addr", "localhost:9000", "HTTP listen address")
+	issuer := fs.String("issuer", "", "issuer URL, defaults to http://<addr>")
+	clientID := fs.String("client-id", "todo-v2", "client ID the server uses")
+	clientSecret := fs.String("client-secret", "mock-secret", "client secret the server uses")
+	fs.Func("user", "user as name[:group,...], may be repeated (default alice:todo-admins and bob)", func(value string) error {
+		name, groups, _ := strings.Cut(value, ":")
+		if name == "" {
+			return errors.New("user name is required")
+		}
+		user := oidc.MockUser{Username: name, Email: name + "@example.com"}
+		if groups != "" {
+			user.Groups = strings.Split(groups, ",")
+		}
+		users = append(users, user)
+		return nil
+	})
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if len(users) == 0 {
+		users = []oidc.MockUser{
+			{Username: "alice", Email: "alice@example.com", Groups: []string{"todo-admins"}},
+			{Username: "bob", Email: "bob@example.com"},
+		}
+	}
+	if *issuer == "" {
+		*issuer = "http://" + *addr
+	}
+
+	provider, err := oidc.NewMockProvider(*issuer, *clientID, *clientSecret, users)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Mock identity provider %s is running on %s...\n", *issuer, *addr)
+	return http.ListenAndServe(*addr, provider)
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	StoreJSON   = "json"
+	StoreSQLite = "sqlite"
+)
+
+// Config holds the startup options. Every option can be set with a command
+// line flag or, as a fallback, an environment variable.
+type Config struct {
+	Addr       string
+	Store      string
+	JSONPath   string
+	SQLitePath string
+
+	// Snapshots of the JSON store; an empty BackupDir disables them.
+	BackupDir      string
+	BackupInterval time.Duration
+	BackupKeep     int
+
+	// Soft-deleted lists and items are purged once they are older than
+	// RetentionWindow. It defaults to zero, which keeps them forever, so
+	// nothing is lost for good unless an operator opts in.
+	RetentionWindow time.Duration
+	PurgeInterval   time.Duration
+
+	// Access tokens are short-lived; clients renew them with a refresh
+	// token, which rotates on every use.
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	// Lifetime of the tokens admins get to act as another user. They
+	// cannot be refreshed.
+	ImpersonationTTL time.Duration
+
+	// Token signing keys; see middleware.KeyConfig.
+	JWTKeyDir     string
+	JWTSecret     string
+	JWTSigningKey string
+
+	// JSON file mapping role names to permissions; empty uses
+	// authz.DefaultRoles.
+	RolesFile string
+
+	// Login throttling; see services.LoginLimiter.
+	LoginMaxFailures   int
+	LoginMaxIPFailures int
+	LoginBackoff       time.Duration
+	LoginLockout       time.Duration
+
+	// Name shown for this service in authenticator apps.
+	TOTPIssuer string
+
+	// Password reset links. Mail goes through SMTPAddr if set and is
+	// written to MailOutboxDir otherwise.
+	PasswordResetTTL time.Duration
+	PasswordResetURL string
+	MailFrom         string
+	MailOutboxDir    string
+	SMTPAddr         string
+	SMTPUsername     string
+	SMTPPassword     string
+
+	// OpenID Connect login; disabled without an issuer. See
+	// services.SSOConfig for how accounts and roles are derived. OIDCRoles
+	// holds comma-separated value=role pairs.
+	OIDCIssuer       string
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCRedirectURL  string
+	OIDCScopes       string
+	OIDCRoleClaim    string
+	OIDCRoles        string
+	OIDCDefaultRole  string
+	OIDCProvision    bool
+}
+
+// Load parses args (without the program name) into a Config.
+func Load(args []string) (*Config, error) {
+	cfg := &Config{}
+	fs := flag.NewFlagSet("todo-v2", flag.ContinueOnError)
+	fs.StringVar(&cfg.Addr, "addr", env("TODO_ADDR", ":8080"), "HTTP listen address")
+	fs.StringVar(&cfg.Store, "store", env("TODO_STORE", StoreJSON), "storage backend: json or sqlite")
+	fs.StringVar(&cfg.JSONPath, "json-path", env("TODO_JSON_PATH", "data/store.json"), "path of the JSON store file")
+	fs.StringVar(&cfg.SQLitePath, "sqlite-path", env("TODO_SQLITE_PATH", "data/store.db"), "path of the SQLite database")
+	fs.StringVar(&cfg.BackupDir, "backup-dir", env("TODO_BACKUP_DIR", "data/backups"), "directory for JSON store snapshots, empty to disable")
+	fs.DurationVar(&cfg.BackupInterval, "backup-interval", envDuration("TODO_BACKUP_INTERVAL", time.Hour), "time between scheduled snapshots, 0 to disable")
+	fs.IntVar(&cfg.BackupKeep, "backup-keep", envInt("TODO_BACKUP_KEEP", 24), "number of snapshots to keep")
+	fs.DurationVar(&cfg.RetentionWindow, "retention", envDuration("TODO_RETENTION", 0), "how long soft-deleted records are kept, 0 (the default) to keep forever")
+	fs.DurationVar(&cfg.PurgeInterval, "purge-interval", envDuration("TODO_PURGE_INTERVAL", time.Hour), "time between purges of expired soft-deleted records")
+	fs.DurationVar(&cfg.AccessTokenTTL, "access-token-ttl", envDuration("TODO_ACCESS_TOKEN_TTL", 15*time.Minute), "lifetime of access tokens")
+	fs.DurationVar(&cfg.RefreshTokenTTL, "refresh-token-ttl", envDuration("TODO_REFRESH_TOKEN_TTL", 30*24*time.Hour), "lifetime of refresh tokens")
+	fs.DurationVar(&cfg.ImpersonationTTL, "impersonation-ttl", envDuration("TODO_IMPERSONATION_TTL", 15*time.Minute), "lifetime of admin impersonation tokens")
+	fs.StringVar(&cfg.JWTKeyDir, "jwt-key-dir", env("TODO_JWT_KEY_DIR", ""), "directory of JWT signing keys (*.pem, *.secret)")
+	fs.StringVar(&cfg.JWTSecret, "jwt-secret", env("TODO_JWT_SECRET", ""), "shared HS256 secret, key ID \"default\"")
+	fs.StringVar(&cfg.JWTSigningKey, "jwt-signing-key", env("TODO_JWT_SIGNING_KEY", ""), "ID of the key that signs new tokens, defaults to the last private key by ID")
+	fs.StringVar(&cfg.RolesFile, "roles-file", env("TODO_ROLES_FILE", ""), "JSON file mapping roles to permissions")
+	fs.IntVar(&cfg.LoginMaxFailures, "login-max-failures", envInt("TODO_LOGIN_MAX_FAILURES", 5), "failed logins that lock a username, 0 to disable")
+	fs.IntVar(&cfg.LoginMaxIPFailures, "login-max-ip-failures", envInt("TODO_LOGIN_MAX_IP_FAILURES", 50), "failed logins that lock a client address, 0 to disable")
+	fs.DurationVar(&cfg.LoginBackoff, "login-backoff", envDuration("TODO_LOGIN_BACKOFF", time.Second), "wait after the first failed login for a username, doubled on every further failure")
+	fs.DurationVar(&cfg.LoginLockout, "login-lockout", envDuration("TODO_LOGIN_LOCKOUT", 15*time.Minute), "how long a locked username or address stays locked")
+	fs.StringVar(&cfg.TOTPIssuer, "totp-issuer", env("TODO_TOTP_ISSUER", "todo-v2"), "service name shown in authenticator apps")
+	fs.DurationVar(&cfg.PasswordResetTTL, "password-reset-ttl", envDuration("TODO_PASSWORD_RESET_TTL", time.Hour), "lifetime of password reset tokens")
+	fs.StringVar(&cfg.PasswordResetURL, "password-reset-url", env("TODO_PASSWORD_RESET_URL", ""), "page password reset emails link to, with the token added as ?token=")
+	fs.StringVar(&cfg.MailFrom, "mail-from", env("TODO_MAIL_FROM", "todo-v2@localhost"), "sender address of outgoing mail")
+	fs.StringVar(&cfg.MailOutboxDir, "mail-outbox-dir", env("TODO_MAIL_OUTBOX_DIR", "data/outbox"), "directory outgoing mail is written to when no SMTP server is set")
+	fs.StringVar(&cfg.SMTPAddr, "smtp-addr", env("TODO_SMTP_ADDR", ""), "SMTP relay (host:port) for outgoing mail")
+	fs.StringVar(&cfg.SMTPUsername, "smtp-username", env("TODO_SMTP_USERNAME", ""), "SMTP username, empty to send without authentication")
+	fs.StringVar(&cfg.SMTPPassword, "smtp-password", env("TODO_SMTP_PASSWORD", ""), "SMTP password")
+	fs.StringVar(&cfg.OIDCIssuer, "oidc-issuer", env("TODO_OIDC_ISSUER", ""), "OpenID Connect issuer URL, empty to disable SSO")
+	fs.StringVar(&cfg.OIDCClientID, "oidc-client-id", env("TODO_OIDC_CLIENT_ID", ""), "client ID registered with the OpenID Connect provider")
+	fs.StringVar(&cfg.OIDCClientSecret, "oidc-client-secret", env("TODO_OIDC_CLIENT_SECRET", ""), "client secret registered with the OpenID Connect provider")
+	fs.StringVar(&cfg.OIDCRedirectURL, "oidc-redirect-url", env("TODO_OIDC_REDIRECT_URL", ""), "URL of /api/oidc/callback as registered with the provider")
+	fs.StringVar(&cfg.OIDCScopes, "oidc-scopes", env("TODO_OIDC_SCOPES", "profile email"), "scopes requested besides openid")
+	fs.StringVar(&cfg.OIDCRoleClaim, "oidc-role-claim", env("TODO_OIDC_ROLE_CLAIM", "groups"), "ID token claim matched against -oidc-roles")
+	fs.StringVar(&cfg.OIDCRoles, "oidc-roles", env("TODO_OIDC_ROLES", ""), "role mappings such as \"todo-admins=admin,staff=user\", first match wins")
+	fs.StringVar(&cfg.OIDCDefaultRole, "oidc-default-role", env("TODO_OIDC_DEFAULT_ROLE", "user"), "role of SSO users no mapping matches, empty to refuse them")
+	fs.BoolVar(&cfg.OIDCProvision, "oidc-provision", envBool("TODO_OIDC_PROVISION", true), "create accounts on the first SSO login")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if cfg.Store != StoreJSON && cfg.Store != StoreSQLite {
+		return nil, fmt.Errorf("unknown store %q, expected %q or %q", cfg.Store, StoreJSON, StoreSQLite)
+	}
+	if cfg.AccessTokenTTL <= 0 || cfg.RefreshTokenTTL <= 0 || cfg.PasswordResetTTL <= 0 || cfg.ImpersonationTTL <= 0 {
+		return nil, fmt.Errorf("token lifetimes must be positive")
+	}
+	if cfg.OIDCIssuer != "" && (cfg.OIDCClientID == "" || cfg.OIDCRedirectURL == "") {
+		return nil, fmt.Errorf("-oidc-issuer requires -oidc-client-id and -oidc-redirect-url")
+	}
+	return cfg, nil
+}
+
+func env(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	if value, ok := os.LookupEnv(key); ok {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return fallback
+}
+
+func envInt(key string, fallback int) int {
+	if value, ok := os.LookupEnv(key); ok {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	}
+	return fallback
+}
+
+func envBool(key string, fallback bool) bool {
+	if value, ok := os.LookupEnv(key); ok {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return fallback
+}
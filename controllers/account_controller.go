@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/YahyaCengiz/todo-v2/services"
+)
+
+// AccountController serves the self-service account endpoints: the email
+// address and the forgotten password flow.
+type AccountController struct {
+	userService  *services.UserService
+	resetService *services.PasswordResetService
+}
+
+func NewAccountController(userService *services.UserService, resetService *services.PasswordResetService) *AccountController {
+	return &AccountController{
+		userService:  userService,
+		resetService: resetService,
+	}
+}
+
+// Email sets the caller's email address, or removes it when empty.
+func (c *AccountController) Email(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// Whoever controls the address can reset the password, so API keys
+	// may not change it.
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+	var request struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, err := c.userService.SetEmail(claims.UserID, request.Email)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(userResponse(user))
+}
+
+// ForgotPassword mails a reset token to the account with the given email
+// address. The answer is the same whether or not there is such an account.
+func (c *AccountController) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var request struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.resetService.RequestReset(request.Email, clientIP(r)); err != nil {
+		var throttled *services.ThrottledError
+		if errors.As(err, &throttled) {
+			writeThrottled(w, throttled)
+			return
+		}
+		if errors.Is(err, services.ErrInvalidInput) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "If an account uses this address, a reset link has been sent to it",
+	})
+}
+
+// ResetPassword sets a new password with a token from ForgotPassword.
+func (c *AccountController) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var request struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Token == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.resetService.ResetPassword(request.Token, request.Password); err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidInput), errors.Is(err, services.ErrInvalidResetToken):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Password changed, please log in again",
+	})
+}
@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/YahyaCengiz/todo-v2/authz"
+	"github.com/YahyaCengiz/todo-v2/middleware"
+	"github.com/YahyaCengiz/todo-v2/services"
+	"github.com/YahyaCengiz/todo-v2/store"
+)
+
+type AdminController struct {
+	backupService    *services.BackupService
+	retentionService *services.RetentionService
+	userService      *services.UserService
+	authz            *authz.Authorizer
+}
+
+func NewAdminController(backupService *services.BackupService, retentionService *services.RetentionService, userService *services.UserService, authorizer *authz.Authorizer) *AdminController {
+	return &AdminController{
+		backupService:    backupService,
+		retentionService: retentionService,
+		userService:      userService,
+		authz:            authorizer,
+	}
+}
+
+// Snapshots lists the available snapshots on GET and takes a new one on POST.
+func (c *AdminController) Snapshots(w http.ResponseWriter, r *http.Request) {
+	if !c.require(w, r, authz.BackupsManage) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		snapshots, err := c.backupService.ListSnapshots()
+		if err != nil {
+			writeBackupError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(snapshots)
+	case http.MethodPost:
+		snapshot, err := c.backupService.CreateSnapshot()
+		if err != nil {
+			writeBackupError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(snapshot)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// RestoreSnapshot restores the snapshot given by ?name=, or the state at the
+// RFC 3339 time given by ?at=.
+func (c *AdminController) RestoreSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !c.require(w, r, authz.BackupsManage) {
+		return
+	}
+
+	name := r.URL.Query().Get("name")
+	atStr := r.URL.Query().Get("at")
+	if (name == "") == (atStr == "") {
+		http.Error(w, "Exactly one of name or at is required", http.StatusBadRequest)
+		return
+	}
+
+	var (
+		snapshot *store.SnapshotInfo
+		err      error
+	)
+	if name != "" {
+		snapshot, err = c.backupService.RestoreSnapshot(name)
+	} else {
+		at, perr := time.Parse(time.RFC3339, atStr)
+		if perr != nil {
+			http.Error(w, "Invalid time, expected RFC 3339", http.StatusBadRequest)
+			return
+		}
+		snapshot, err = c.backupService.RestoreToTime(at)
+	}
+	if err != nil {
+		writeBackupError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":  "Store restored",
+		"snapshot": snapshot,
+	})
+}
+
+// Purge permanently removes soft-deleted lists and items right away. By
+// default it uses the retention window; ?older_than= (a Go duration such as
+// 0s or 72h) overrides it, and is required when records are kept forever.
+func (c *AdminController) Purge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !c.require(w, r, authz.RetentionPurge) {
+		return
+	}
+
+	olderThan := c.retentionService.Window()
+	if olderThanStr := r.URL.Query().Get("older_than"); olderThanStr != "" {
+		d, err := time.ParseDuration(olderThanStr)
+		if err != nil || d < 0 {
+			http.Error(w, "Invalid older_than duration", http.StatusBadRequest)
+			return
+		}
+		olderThan = d
+	} else if olderThan <= 0 {
+		http.Error(w, "older_than is required when no retention window is configured", http.StatusBadRequest)
+		return
+	}
+
+	result, err := c.retentionService.Purge(olderThan)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"older_than": olderThan.String(),
+		"list_ids":   result.ListIDs,
+		"items":      result.Items,
+	})
+}
+
+func (c *AdminController) require(w http.ResponseWriter, r *http.Request, perm authz.Permission) bool {
+	claims := r.Context().Value("claims").(*middleware.Claims)
+	if !c.authz.Can(subject(claims), perm) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// subject is who the request acts as, for the authorizer.
+func subject(claims *middleware.Claims) authz.Subject {
+	return authz.Subject{UserID: claims.UserID, OrgID: claims.OrgID, Role: claims.Role}
+}
+
+func writeBackupError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrBackupsUnsupported), errors.Is(err, store.ErrBackupsDisabled):
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+	case errors.Is(err, store.ErrSnapshotNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
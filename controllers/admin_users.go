@@ -0,0 +1,305 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/YahyaCengiz/todo-v2/authz"
+	"github.com/YahyaCengiz/todo-v2/middleware"
+	"github.com/YahyaCengiz/todo-v2/models"
+	"github.com/YahyaCengiz/todo-v2/services"
+	"github.com/YahyaCengiz/todo-v2/store"
+)
+
+// Users lists the accounts of the caller's organization, or of the one
+// given by ?org_id=, on GET and creates one on POST. PUT changes the role
+// and DELETE removes the account given by ?id=.
+func (c *AdminController) Users(w http.ResponseWriter, r *http.Request) {
+	if !c.require(w, r, authz.UsersManage) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		c.listUsers(w, r)
+	case http.MethodPost:
+		c.createUser(w, r)
+	case http.MethodPut:
+		c.setRole(w, r)
+	case http.MethodDelete:
+		c.deleteUser(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (c *AdminController) listUsers(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*middleware.Claims)
+	var orgID int
+	if orgIDStr := r.URL.Query().Get("org_id"); orgIDStr != "" {
+		id, err := strconv.Atoi(orgIDStr)
+		if err != nil {
+			http.Error(w, "Invalid org_id", http.StatusBadRequest)
+			return
+		}
+		orgID = id
+	}
+
+	users, err := c.userService.ListUsers(orgID, subject(claims))
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	response := make([]map[string]interface{}, 0, len(users))
+	for i := range users {
+		response = append(response, userResponse(&users[i]))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (c *AdminController) createUser(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*middleware.Claims)
+	var request struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Role     string `json:"role"`
+		Email    string `json:"email"`
+		OrgID    int    `json:"org_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, err := c.userService.CreateUser(request.Username, request.Password, request.Role, request.Email, request.OrgID, subject(claims))
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(userResponse(user))
+}
+
+func (c *AdminController) setRole(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*middleware.Claims)
+	id, ok := userID(w, r)
+	if !ok {
+		return
+	}
+	var request struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, err := c.userService.SetRole(id, request.Role, subject(claims))
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(userResponse(user))
+}
+
+func (c *AdminController) deleteUser(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*middleware.Claims)
+	id, ok := userID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.userService.DeleteUser(id, subject(claims)); err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResetPassword sets a new password for the user given by ?id= and ends
+// all of their sessions.
+func (c *AdminController) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !c.require(w, r, authz.UsersManage) {
+		return
+	}
+	claims := r.Context().Value("claims").(*middleware.Claims)
+	id, ok := userID(w, r)
+	if !ok {
+		return
+	}
+	var request struct {
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, err := c.userService.ResetPassword(id, request.Password, subject(claims))
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(userResponse(user))
+}
+
+func (c *AdminController) DeactivateUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !c.require(w, r, authz.UsersManage) {
+		return
+	}
+	claims := r.Context().Value("claims").(*middleware.Claims)
+	id, ok := userID(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := c.userService.Deactivate(id, subject(claims))
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(userResponse(user))
+}
+
+func (c *AdminController) ReactivateUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !c.require(w, r, authz.UsersManage) {
+		return
+	}
+	claims := r.Context().Value("claims").(*middleware.Claims)
+	id, ok := userID(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := c.userService.Reactivate(id, subject(claims))
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(userResponse(user))
+}
+
+// UnlockUser lifts a login lockout of the user given by ?id=.
+func (c *AdminController) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !c.require(w, r, authz.UsersManage) {
+		return
+	}
+	claims := r.Context().Value("claims").(*middleware.Claims)
+	id, ok := userID(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := c.userService.Unlock(id, subject(claims))
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(userResponse(user))
+}
+
+// ResetMFA removes the second factor of the user given by ?id=, for users
+// who lost their authenticator and recovery codes.
+func (c *AdminController) ResetMFA(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !c.require(w, r, authz.UsersManage) {
+		return
+	}
+	claims := r.Context().Value("claims").(*middleware.Claims)
+	id, ok := userID(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := c.userService.ResetMFA(id, subject(claims))
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(userResponse(user))
+}
+
+func userID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	idStr := r.URL.Query().Get("id")
+	if idStr == "" {
+		http.Error(w, "ID is required", http.StatusBadRequest)
+		return 0, false
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// userResponse leaves out the password hash and second factor secrets.
+func userResponse(user *models.User) map[string]interface{} {
+	response := map[string]interface{}{
+		"id":             user.ID,
+		"org_id":         user.OrgID,
+		"username":       user.Username,
+		"role":           user.Role,
+		"email":          user.Email,
+		"deactivated_at": nil,
+		"mfa_enabled":    user.TOTPEnabled,
+		"sso":            user.SSOSubject != "",
+	}
+	if !user.DeactivatedAt.IsZero() {
+		response["deactivated_at"] = user.DeactivatedAt
+	}
+	return response
+}
+
+func writeUserError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidInput):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, authz.ErrForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, store.ErrUserNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, store.ErrUsernameTaken), errors.Is(err, store.ErrEmailTaken):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
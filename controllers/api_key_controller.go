@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/YahyaCengiz/todo-v2/authz"
+	"github.com/YahyaCengiz/todo-v2/middleware"
+	"github.com/YahyaCengiz/todo-v2/models"
+	"github.com/YahyaCengiz/todo-v2/services"
+)
+
+type APIKeyController struct {
+	apiKeyService *services.APIKeyService
+}
+
+func NewAPIKeyController(apiKeyService *services.APIKeyService) *APIKeyController {
+	return &APIKeyController{apiKeyService: apiKeyService}
+}
+
+// APIKeys lists the caller's keys on GET, creates one on POST and revokes
+// the one given by ?id= on DELETE.
+func (c *APIKeyController) APIKeys(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		c.listAPIKeys(w, r)
+	case http.MethodPost:
+		c.createAPIKey(w, r)
+	case http.MethodDelete:
+		c.revokeAPIKey(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (c *APIKeyController) listAPIKeys(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*middleware.Claims)
+
+	keys, err := c.apiKeyService.ListAPIKeys(claims.UserID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]map[string]interface{}, 0, len(keys))
+	for i := range keys {
+		response = append(response, apiKeyResponse(&keys[i]))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (c *APIKeyController) createAPIKey(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*middleware.Claims)
+	// A leaked key must not be able to mint more keys.
+	if claims.APIKeyID != 0 {
+		http.Error(w, "API keys cannot create API keys", http.StatusForbidden)
+		return
+	}
+	// Nor may an admin keep access to a user after impersonating them.
+	if claims.Actor != nil {
+		http.Error(w, "Impersonation tokens cannot create API keys", http.StatusForbidden)
+		return
+	}
+
+	var request struct {
+		Name      string    `json:"name"`
+		ReadOnly  bool      `json:"read_only"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	key, plaintext, err := c.apiKeyService.CreateAPIKey(subject(claims), request.Name, request.ReadOnly, request.ExpiresAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidInput):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, authz.ErrForbidden):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	response := apiKeyResponse(key)
+	response["key"] = plaintext
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (c *APIKeyController) revokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*middleware.Claims)
+	idStr := r.URL.Query().Get("id")
+	if idStr == "" {
+		http.Error(w, "ID is required", http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.apiKeyService.RevokeAPIKey(id, subject(claims)); err != nil {
+		if errors.Is(err, services.ErrAPIKeyNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiKeyResponse leaves out the key hash.
+func apiKeyResponse(key *models.APIKey) map[string]interface{} {
+	response := map[string]interface{}{
+		"id":         key.ID,
+		"name":       key.Name,
+		"prefix":     key.Prefix,
+		"read_only":  key.ReadOnly,
+		"created_at": key.CreatedAt,
+		"expires_at": nil,
+		"revoked_at": nil,
+	}
+	if !key.ExpiresAt.IsZero() {
+		response["expires_at"] = key.ExpiresAt
+	}
+	if !key.RevokedAt.IsZero() {
+		response["revoked_at"] = key.RevokedAt
+	}
+	return response
+}
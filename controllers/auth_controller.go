@@ -2,19 +2,35 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
 
 	"github.com/YahyaCengiz/todo-v2/middleware"
+	"github.com/YahyaCengiz/todo-v2/models"
+	"github.com/YahyaCengiz/todo-v2/oidc"
 	"github.com/YahyaCengiz/todo-v2/services"
+	"github.com/YahyaCengiz/todo-v2/store"
 )
 
+// ssoStateCookie keeps the state of an SSO login between SSOLogin and
+// SSOCallback.
+const ssoStateCookie = "todo_sso_state"
+
 type AuthController struct {
-	userService *services.UserService
+	userService  *services.UserService
+	tokenService *services.TokenService
+	// ssoService is nil when SSO is not configured.
+	ssoService *services.SSOService
 }
 
-func NewAuthController(userService *services.UserService) *AuthController {
+func NewAuthController(userService *services.UserService, tokenService *services.TokenService, ssoService *services.SSOService) *AuthController {
 	return &AuthController{
-		userService: userService,
+		userService:  userService,
+		tokenService: tokenService,
+		ssoService:   ssoService,
 	}
 }
 
@@ -27,6 +43,8 @@ func (c *AuthController) Login(w http.ResponseWriter, r *http.Request) {
 	var loginRequest struct {
 		Username string `json:"username"`
 		Password string `json:"password"`
+		// Device optionally labels the session, e.g. "Work laptop".
+		Device string `json:"device"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&loginRequest); err != nil {
@@ -34,26 +52,291 @@ func (c *AuthController) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := c.userService.Login(loginRequest.Username, loginRequest.Password)
+	user, err := c.userService.Login(loginRequest.Username, loginRequest.Password, clientIP(r))
+	if err != nil {
+		var throttled *services.ThrottledError
+		if errors.As(err, &throttled) {
+			writeThrottled(w, throttled)
+			return
+		}
+		http.Error(w, services.ErrInvalidCredentials.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if user.TOTPEnabled {
+		c.writeChallenge(w, user)
+		return
+	}
+	c.writeToken(w, r, http.StatusOK, "Login successful", user, loginRequest.Device)
+}
+
+// LoginMFA completes a login that needs a second factor. It takes the
+// challenge token from Login and either a TOTP code or a recovery code.
+func (c *AuthController) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var mfaRequest struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+		Device         string `json:"device"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&mfaRequest); err != nil || mfaRequest.ChallengeToken == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := c.tokenService.VerifyChallenge(mfaRequest.ChallengeToken)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
+	user, err := c.userService.LoginSecondFactor(userID, mfaRequest.Code, clientIP(r))
+	if err != nil {
+		var throttled *services.ThrottledError
+		switch {
+		case errors.As(err, &throttled):
+			writeThrottled(w, throttled)
+		case errors.Is(err, services.ErrInvalidMFACode):
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		default:
+			http.Error(w, services.ErrInvalidCredentials.Error(), http.StatusUnauthorized)
+		}
+		return
+	}
+
+	c.writeToken(w, r, http.StatusOK, "Login successful", user, mfaRequest.Device)
+}
+
+// Register creates a regular user account and logs it in.
+func (c *AuthController) Register(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var registerRequest struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Email    string `json:"email"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&registerRequest); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, err := c.userService.Register(registerRequest.Username, registerRequest.Password, registerRequest.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidInput):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, store.ErrUsernameTaken), errors.Is(err, store.ErrEmailTaken):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	c.writeToken(w, r, http.StatusCreated, "Registration successful", user, "")
+}
+
+// SSOLogin sends the browser to the identity provider to log in.
+func (c *AuthController) SSOLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if c.ssoService == nil {
+		http.Error(w, services.ErrSSONotConfigured.Error(), http.StatusNotFound)
+		return
+	}
+
+	login, err := c.ssoService.Begin(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     ssoStateCookie,
+		Value:    login.State,
+		Path:     "/api/oidc",
+		MaxAge:   login.ExpiresIn,
+		Secure:   login.Secure,
+		HttpOnly: true,
+		// Lax still sends the cookie along with the provider's redirect.
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, login.URL, http.StatusFound)
+}
+
+// SSOCallback is where the identity provider sends the browser back. It
+// answers like Login.
+func (c *AuthController) SSOCallback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if c.ssoService == nil {
+		http.Error(w, services.ErrSSONotConfigured.Error(), http.StatusNotFound)
+		return
+	}
+	query := r.URL.Query()
+	if reason := query.Get("error"); reason != "" {
+		http.Error(w, "The identity provider refused the login: "+reason, http.StatusUnauthorized)
+		return
+	}
+	cookie, err := r.Cookie(ssoStateCookie)
+	if err != nil {
+		http.Error(w, services.ErrInvalidSSOState.Error(), http.StatusBadRequest)
+		return
+	}
+	// Every state is good for one attempt.
+	http.SetCookie(w, &http.Cookie{Name: ssoStateCookie, Path: "/api/oidc", MaxAge: -1})
+
+	user, err := c.ssoService.Complete(r.Context(), query.Get("code"), query.Get("state"), cookie.Value)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidSSOState):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, oidc.ErrInvalidIDToken), errors.Is(err, oidc.ErrCodeRejected):
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		case errors.Is(err, services.ErrSSODenied):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		}
+		return
+	}
+
+	if user.TOTPEnabled {
+		c.writeChallenge(w, user)
+		return
+	}
+	c.writeToken(w, r, http.StatusOK, "Login successful", user, "")
+}
+
+// Refresh trades a refresh token for a new access and refresh token.
+func (c *AuthController) Refresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var refreshRequest struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&refreshRequest); err != nil || refreshRequest.RefreshToken == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
 
-	token, err := middleware.GenerateToken(user.ID, user.Username, user.Role)
+	pair, user, err := c.tokenService.Refresh(refreshRequest.RefreshToken)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRefreshToken) || errors.Is(err, services.ErrRefreshTokenReused) {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeTokenPair(w, http.StatusOK, "Token refreshed", pair, user)
+}
+
+// Logout revokes the session of the access token used to call it.
+func (c *AuthController) Logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims := r.Context().Value("claims").(*middleware.Claims)
+	if claims.SessionID == "" {
+		http.Error(w, "API keys are revoked through /api/api-keys", http.StatusBadRequest)
+		return
+	}
+	// The session is the admin's own, which the impersonated user's
+	// logout must not end.
+	if claims.Actor != nil {
+		http.Error(w, "Impersonation tokens cannot log out, they expire on their own", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.tokenService.RevokeSession(claims.SessionID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Logout successful",
+	})
+}
+
+// writeToken starts a session for user on the device that sent r.
+func (c *AuthController) writeToken(w http.ResponseWriter, r *http.Request, status int, message string, user *models.User, device string) {
+	pair, err := c.tokenService.Issue(user, services.SessionInfo{
+		Device:    device,
+		IP:        clientIP(r),
+		UserAgent: r.UserAgent(),
+	})
 	if err != nil {
 		http.Error(w, "Error generating token", http.StatusInternalServerError)
 		return
 	}
+	writeTokenPair(w, status, message, pair, user)
+}
 
+// writeChallenge answers a correct password with a challenge token instead
+// of a session, to be traded in at /api/login/mfa.
+func (c *AuthController) writeChallenge(w http.ResponseWriter, user *models.User) {
+	challenge, err := c.tokenService.IssueChallenge(user)
+	if err != nil {
+		http.Error(w, "Error generating token", http.StatusInternalServerError)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message": "Login successful",
-		"token":   token,
+		"message":         "Second factor required",
+		"mfa_required":    true,
+		"challenge_token": challenge.Token,
+		"expires_in":      challenge.ExpiresIn,
+	})
+}
+
+func writeTokenPair(w http.ResponseWriter, status int, message string, pair *services.TokenPair, user *models.User) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":       message,
+		"token":         pair.AccessToken,
+		"refresh_token": pair.RefreshToken,
+		"expires_in":    pair.ExpiresIn,
 		"user": map[string]interface{}{
 			"id":       user.ID,
+			"org_id":   user.OrgID,
 			"username": user.Username,
 			"role":     user.Role,
 		},
 	})
-} 
\ No newline at end of file
+} 
+
+func writeThrottled(w http.ResponseWriter, err *services.ThrottledError) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(err.RetryAfter.Seconds()))))
+	http.Error(w, err.Error(), http.StatusTooManyRequests)
+}
+
+// clientIP is the address the request came from. Headers such as
+// X-Forwarded-For are ignored since any client can set them.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
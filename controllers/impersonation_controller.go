@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/YahyaCengiz/todo-v2/authz"
+	"github.com/YahyaCengiz/todo-v2/services"
+	"github.com/YahyaCengiz/todo-v2/store"
+)
+
+// ImpersonationController lets admins act as another user and read the
+// audit log of what they did meanwhile.
+type ImpersonationController struct {
+	impersonationService *services.ImpersonationService
+}
+
+func NewImpersonationController(impersonationService *services.ImpersonationService) *ImpersonationController {
+	return &ImpersonationController{impersonationService: impersonationService}
+}
+
+// Impersonate returns a token acting as the user given by ?id=. Every
+// request made with it is answered with an X-Impersonated-By header and
+// recorded in the audit log.
+func (c *ImpersonationController) Impersonate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// Impersonation tokens belong to the admin's session, which API keys
+	// and other impersonation tokens do not have.
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+	id, ok := userID(w, r)
+	if !ok {
+		return
+	}
+
+	impersonation, user, err := c.impersonationService.Impersonate(claims, id, clientIP(r))
+	if err != nil {
+		if errors.Is(err, authz.ErrForbidden) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		writeUserError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":    "Impersonating " + user.Username,
+		"token":      impersonation.Token,
+		"expires_in": impersonation.ExpiresIn,
+		"user": map[string]interface{}{
+			"id":       user.ID,
+			"org_id":   user.OrgID,
+			"username": user.Username,
+			"role":     user.Role,
+		},
+	})
+}
+
+// AuditLog lists the audit entries of the caller's organization, or of the
+// one given by ?org_id=, optionally only those of the admin given by
+// ?actor_id= or about the user given by ?user_id=.
+func (c *ImpersonationController) AuditLog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	var filter store.AuditFilter
+	for name, field := range map[string]*int{"org_id": &filter.OrgID, "actor_id": &filter.ActorID, "user_id": &filter.UserID} {
+		value := r.URL.Query().Get(name)
+		if value == "" {
+			continue
+		}
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Invalid "+name, http.StatusBadRequest)
+			return
+		}
+		*field = id
+	}
+
+	entries, err := c.impersonationService.AuditLog(subject(claims), filter)
+	if err != nil {
+		if errors.Is(err, authz.ErrForbidden) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
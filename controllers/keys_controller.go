@@ -0,0 +1,30 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/YahyaCengiz/todo-v2/middleware"
+)
+
+type KeysController struct {
+	keys *middleware.KeySet
+}
+
+func NewKeysController(keys *middleware.KeySet) *KeysController {
+	return &KeysController{keys: keys}
+}
+
+// JWKS publishes the public token verification keys.
+func (c *KeysController) JWKS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": c.keys.JWKS(),
+	})
+}
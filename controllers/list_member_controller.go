@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/YahyaCengiz/todo-v2/authz"
+	"github.com/YahyaCengiz/todo-v2/middleware"
+	"github.com/YahyaCengiz/todo-v2/services"
+	"github.com/YahyaCengiz/todo-v2/store"
+)
+
+// ListMembers manages who a list is shared with. GET lists the owner and
+// collaborators of ?list_id=, POST invites a user, and PUT and DELETE
+// change or remove the collaborator given by &user_id=.
+func (c *TodoController) ListMembers(w http.ResponseWriter, r *http.Request) {
+	listIDStr := r.URL.Query().Get("list_id")
+	if listIDStr == "" {
+		http.Error(w, "List ID is required", http.StatusBadRequest)
+		return
+	}
+	listID, err := strconv.Atoi(listIDStr)
+	if err != nil {
+		http.Error(w, "Invalid List ID", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		c.getListMembers(w, r, listID)
+	case http.MethodPost:
+		c.addListMember(w, r, listID)
+	case http.MethodPut:
+		c.updateListMember(w, r, listID)
+	case http.MethodDelete:
+		c.removeListMember(w, r, listID)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (c *TodoController) getListMembers(w http.ResponseWriter, r *http.Request, listID int) {
+	claims := r.Context().Value("claims").(*middleware.Claims)
+
+	members, err := c.todoService.GetListMembers(listID, subject(claims))
+	if err != nil {
+		writeListMemberError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(members)
+}
+
+func (c *TodoController) addListMember(w http.ResponseWriter, r *http.Request, listID int) {
+	claims := r.Context().Value("claims").(*middleware.Claims)
+	var request struct {
+		Username string `json:"username"`
+		Role     string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	member, err := c.todoService.AddListMember(listID, request.Username, request.Role, subject(claims))
+	if err != nil {
+		writeListMemberError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(member)
+}
+
+func (c *TodoController) updateListMember(w http.ResponseWriter, r *http.Request, listID int) {
+	claims := r.Context().Value("claims").(*middleware.Claims)
+	userID, ok := memberUserID(w, r)
+	if !ok {
+		return
+	}
+	var request struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	member, err := c.todoService.UpdateListMember(listID, userID, request.Role, subject(claims))
+	if err != nil {
+		writeListMemberError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(member)
+}
+
+func (c *TodoController) removeListMember(w http.ResponseWriter, r *http.Request, listID int) {
+	claims := r.Context().Value("claims").(*middleware.Claims)
+	userID, ok := memberUserID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.todoService.RemoveListMember(listID, userID, subject(claims)); err != nil {
+		writeListMemberError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func memberUserID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, "User ID is required", http.StatusBadRequest)
+		return 0, false
+	}
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, "Invalid User ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return userID, true
+}
+
+func writeListMemberError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidInput):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, authz.ErrForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, store.ErrListNotFound), errors.Is(err, store.ErrUserNotFound), errors.Is(err, services.ErrMemberNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrAlreadyMember):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/YahyaCengiz/todo-v2/authz"
+	"github.com/YahyaCengiz/todo-v2/middleware"
+	"github.com/YahyaCengiz/todo-v2/services"
+)
+
+// MFAController lets users set up and remove a TOTP second factor.
+type MFAController struct {
+	userService *services.UserService
+}
+
+func NewMFAController(userService *services.UserService) *MFAController {
+	return &MFAController{userService: userService}
+}
+
+// TOTP starts an enrolment on POST and turns the second factor off on
+// DELETE.
+func (c *MFAController) TOTP(w http.ResponseWriter, r *http.Request) {
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		enrollment, err := c.userService.EnrollTOTP(subject(claims))
+		if err != nil {
+			writeMFAError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(enrollment)
+	case http.MethodDelete:
+		code, ok := mfaCode(w, r)
+		if !ok {
+			return
+		}
+		if err := c.userService.DisableTOTP(subject(claims), code, clientIP(r)); err != nil {
+			writeMFAError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ConfirmTOTP finishes an enrolment with a code from the authenticator
+// app and returns the recovery codes.
+func (c *MFAController) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+	code, ok := mfaCode(w, r)
+	if !ok {
+		return
+	}
+
+	codes, err := c.userService.ConfirmTOTP(subject(claims), code)
+	if err != nil {
+		writeMFAError(w, err)
+		return
+	}
+	writeRecoveryCodes(w, codes)
+}
+
+// RecoveryCodes replaces the caller's recovery codes.
+func (c *MFAController) RecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+	code, ok := mfaCode(w, r)
+	if !ok {
+		return
+	}
+
+	codes, err := c.userService.RegenerateRecoveryCodes(subject(claims), code, clientIP(r))
+	if err != nil {
+		writeMFAError(w, err)
+		return
+	}
+	writeRecoveryCodes(w, codes)
+}
+
+// sessionClaims returns the caller's claims unless they come from an API
+// key or an impersonation token. Account security settings such as second
+// factors and logged-in devices protect interactive logins, which keys
+// bypass, so keys may not change them either; and an admin acting as a
+// user only gets to see what the user sees, not to take over the account.
+func sessionClaims(w http.ResponseWriter, r *http.Request) (*middleware.Claims, bool) {
+	claims := r.Context().Value("claims").(*middleware.Claims)
+	if claims.APIKeyID != 0 {
+		http.Error(w, "API keys cannot manage account security settings", http.StatusForbidden)
+		return nil, false
+	}
+	if claims.Actor != nil {
+		http.Error(w, "Impersonation tokens cannot manage account security settings", http.StatusForbidden)
+		return nil, false
+	}
+	return claims, true
+}
+
+func mfaCode(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var request struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Code == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return "", false
+	}
+	return request.Code, true
+}
+
+func writeRecoveryCodes(w http.ResponseWriter, codes []string) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"recovery_codes": codes,
+	})
+}
+
+func writeMFAError(w http.ResponseWriter, err error) {
+	var throttled *services.ThrottledError
+	switch {
+	case errors.As(err, &throttled):
+		writeThrottled(w, throttled)
+	case errors.Is(err, authz.ErrForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, services.ErrInvalidMFACode):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrMFAEnabled), errors.Is(err, services.ErrMFANotEnabled), errors.Is(err, services.ErrMFANotEnrolled):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
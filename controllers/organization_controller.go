@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/YahyaCengiz/todo-v2/authz"
+	"github.com/YahyaCengiz/todo-v2/middleware"
+	"github.com/YahyaCengiz/todo-v2/services"
+	"github.com/YahyaCengiz/todo-v2/store"
+)
+
+type OrganizationController struct {
+	organizationService *services.OrganizationService
+}
+
+func NewOrganizationController(organizationService *services.OrganizationService) *OrganizationController {
+	return &OrganizationController{organizationService: organizationService}
+}
+
+// Organization returns the caller's organization.
+func (c *OrganizationController) Organization(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims := r.Context().Value("claims").(*middleware.Claims)
+
+	org, err := c.organizationService.Organization(subject(claims))
+	if err != nil {
+		writeOrganizationError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(org)
+}
+
+// Organizations lists every organization on GET and creates one on POST.
+func (c *OrganizationController) Organizations(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*middleware.Claims)
+
+	switch r.Method {
+	case http.MethodGet:
+		orgs, err := c.organizationService.ListOrganizations(subject(claims))
+		if err != nil {
+			writeOrganizationError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(orgs)
+	case http.MethodPost:
+		var request struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		org, err := c.organizationService.CreateOrganization(request.Name, subject(claims))
+		if err != nil {
+			writeOrganizationError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(org)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeOrganizationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidInput):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, authz.ErrForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, store.ErrOrganizationNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, store.ErrOrganizationNameTaken):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/YahyaCengiz/todo-v2/models"
+	"github.com/YahyaCengiz/todo-v2/services"
+	"github.com/YahyaCengiz/todo-v2/store"
+)
+
+// SessionController lets users see where they are logged in and log out
+// other devices.
+type SessionController struct {
+	tokenService *services.TokenService
+}
+
+func NewSessionController(tokenService *services.TokenService) *SessionController {
+	return &SessionController{tokenService: tokenService}
+}
+
+// Sessions lists the caller's active sessions. The one the request was
+// made with is marked as current.
+func (c *SessionController) Sessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	sessions, err := c.tokenService.ListSessions(claims.UserID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]map[string]interface{}, 0, len(sessions))
+	for i := range sessions {
+		response = append(response, sessionResponse(&sessions[i], claims.SessionID))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// RevokeSession logs out the session given in the path. Its access tokens
+// stop working at once and its refresh token can no longer be used.
+func (c *SessionController) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.tokenService.RevokeUserSession(claims.UserID, r.PathValue("id")); err != nil {
+		if errors.Is(err, store.ErrSessionNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func sessionResponse(session *models.Session, currentID string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":           session.ID,
+		"device":       session.Device,
+		"ip":           session.IP,
+		"user_agent":   session.UserAgent,
+		"created_at":   session.CreatedAt,
+		"last_seen_at": session.LastSeenAt,
+		"expires_at":   session.ExpiresAt,
+		"current":      session.ID == currentID,
+	}
+}
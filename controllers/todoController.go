@@ -2,11 +2,14 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
+	"github.com/YahyaCengiz/todo-v2/authz"
 	"github.com/YahyaCengiz/todo-v2/middleware"
 	"github.com/YahyaCengiz/todo-v2/services"
+	"github.com/YahyaCengiz/todo-v2/store"
 )
 
 type TodoController struct {
@@ -28,9 +31,9 @@ func (c *TodoController) CreateTodoList(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	todoList, err := c.todoService.CreateTodoList(request.Name, claims.UserID)
+	todoList, err := c.todoService.CreateTodoList(request.Name, subject(claims))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeTodoError(w, err)
 		return
 	}
 
@@ -42,7 +45,7 @@ func (c *TodoController) GetTodoList(w http.ResponseWriter, r *http.Request) {
 	claims := r.Context().Value("claims").(*middleware.Claims)
 	idStr := r.URL.Query().Get("id")
 	if idStr == "" {
-		todoLists, err := c.todoService.GetAllTodoLists(claims.UserID, claims.Role)
+		todoLists, err := c.todoService.GetAllTodoLists(subject(claims))
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -58,7 +61,7 @@ func (c *TodoController) GetTodoList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	todoList, err := c.todoService.GetTodoList(id, claims.UserID, claims.Role)
+	todoList, err := c.todoService.GetTodoList(id, subject(claims))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
@@ -91,9 +94,9 @@ func (c *TodoController) UpdateTodoList(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	todoList, err := c.todoService.UpdateTodoList(id, request.Name, claims.UserID, claims.Role)
+	todoList, err := c.todoService.UpdateTodoList(id, request.Name, subject(claims))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeTodoError(w, err)
 		return
 	}
 
@@ -115,8 +118,8 @@ func (c *TodoController) DeleteTodoList(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := c.todoService.DeleteTodoList(id, claims.UserID, claims.Role); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := c.todoService.DeleteTodoList(id, subject(claims)); err != nil {
+		writeTodoError(w, err)
 		return
 	}
 
@@ -146,9 +149,9 @@ func (c *TodoController) CreateTodoItem(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	todoItem, err := c.todoService.CreateTodoItem(listID, request.Content, claims.UserID, claims.Role)
+	todoItem, err := c.todoService.CreateTodoItem(listID, request.Content, subject(claims))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeTodoError(w, err)
 		return
 	}
 
@@ -187,9 +190,9 @@ func (c *TodoController) UpdateTodoItem(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	todoItem, err := c.todoService.UpdateTodoItem(listID, itemID, request.Content, request.IsCompleted, claims.UserID, claims.Role)
+	todoItem, err := c.todoService.UpdateTodoItem(listID, itemID, request.Content, request.IsCompleted, subject(claims))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeTodoError(w, err)
 		return
 	}
 
@@ -218,10 +221,23 @@ func (c *TodoController) DeleteTodoItem(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := c.todoService.DeleteTodoItem(listID, itemID, claims.UserID, claims.Role); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := c.todoService.DeleteTodoItem(listID, itemID, subject(claims)); err != nil {
+		writeTodoError(w, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
-} 
\ No newline at end of file
+} 
+
+func writeTodoError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, authz.ErrForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, store.ErrListNotFound), errors.Is(err, store.ErrItemNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrListNotDeleted), errors.Is(err, services.ErrListDeleted), errors.Is(err, services.ErrItemNotDeleted):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/YahyaCengiz/todo-v2/middleware"
+)
+
+func (c *TodoController) GetTrash(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims := r.Context().Value("claims").(*middleware.Claims)
+
+	trash, err := c.todoService.GetTrash(subject(claims))
+	if err != nil {
+		writeTodoError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(trash)
+}
+
+func (c *TodoController) RestoreTodoList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims := r.Context().Value("claims").(*middleware.Claims)
+	idStr := r.URL.Query().Get("id")
+	if idStr == "" {
+		http.Error(w, "ID is required", http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	todoList, err := c.todoService.RestoreTodoList(id, subject(claims))
+	if err != nil {
+		writeTodoError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(todoList)
+}
+
+func (c *TodoController) RestoreTodoItem(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims := r.Context().Value("claims").(*middleware.Claims)
+	listIDStr := r.URL.Query().Get("list_id")
+	itemIDStr := r.URL.Query().Get("item_id")
+	if listIDStr == "" || itemIDStr == "" {
+		http.Error(w, "List ID and Item ID are required", http.StatusBadRequest)
+		return
+	}
+
+	listID, err := strconv.Atoi(listIDStr)
+	if err != nil {
+		http.Error(w, "Invalid List ID", http.StatusBadRequest)
+		return
+	}
+
+	itemID, err := strconv.Atoi(itemIDStr)
+	if err != nil {
+		http.Error(w, "Invalid Item ID", http.StatusBadRequest)
+		return
+	}
+
+	todoItem, err := c.todoService.RestoreTodoItem(listID, itemID, subject(claims))
+	if err != nil {
+		writeTodoError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(todoItem)
+}
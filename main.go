@@ -2,25 +2,110 @@ package main
 
 import (
 	"fmt"
+	"log"
 	"net/http"
+	"os"
+	"strings"
 
+	"github.com/YahyaCengiz/todo-v2/authz"
+	"github.com/YahyaCengiz/todo-v2/config"
 	"github.com/YahyaCengiz/todo-v2/controllers"
+	"github.com/YahyaCengiz/todo-v2/mail"
 	"github.com/YahyaCengiz/todo-v2/middleware"
+	"github.com/YahyaCengiz/todo-v2/oidc"
 	"github.com/YahyaCengiz/todo-v2/services"
 	"github.com/YahyaCengiz/todo-v2/store"
 )
 
 func main() {
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		if err := runCommand(os.Args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	store := store.NewStore()
-	todoService := services.NewTodoService(store)
-	userService := services.NewUserService(store)
+	repo, err := openStore(cfg)
+	if err != nil {
+		log.Fatalf("Failed to open %s store: %v", cfg.Store, err)
+	}
+	roles, err := authz.LoadRoles(cfg.RolesFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	authorizer, err := authz.New(roles)
+	if err != nil {
+		log.Fatalf("Invalid roles: %v", err)
+	}
+
+	todoService := services.NewTodoService(repo, authorizer)
+	loginLimiter := services.NewLoginLimiter(cfg.LoginMaxFailures, cfg.LoginMaxIPFailures, cfg.LoginBackoff, cfg.LoginLockout)
+	go loginLimiter.Run(cfg.LoginLockout)
+	userService := services.NewUserService(repo, authorizer, loginLimiter, cfg.TOTPIssuer)
+	backupService := services.NewBackupService(repo)
+	go backupService.Run(cfg.BackupInterval)
+	retentionService := services.NewRetentionService(repo, cfg.RetentionWindow)
+	go retentionService.Run(cfg.PurgeInterval)
+	keys, err := middleware.LoadKeySet(middleware.KeyConfig{
+		Dir:          cfg.JWTKeyDir,
+		Secret:       cfg.JWTSecret,
+		SigningKeyID: cfg.JWTSigningKey,
+	})
+	if err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+	tokenService := services.NewTokenService(repo, keys, cfg.AccessTokenTTL, cfg.RefreshTokenTTL)
+	go tokenService.Run(cfg.PurgeInterval)
+	apiKeyService := services.NewAPIKeyService(repo, authorizer)
+	mailer, err := openMailer(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	resetService := services.NewPasswordResetService(repo, keys, mailer, loginLimiter, cfg.PasswordResetTTL, cfg.PasswordResetURL)
+	impersonationService := services.NewImpersonationService(repo, authorizer, keys, cfg.ImpersonationTTL)
+	organizationService := services.NewOrganizationService(repo, authorizer)
+	ssoService, err := openSSO(cfg, repo, authorizer, keys)
+	if err != nil {
+		log.Fatal(err)
+	}
+	auth := middleware.NewAuthenticator(keys, tokenService, apiKeyService, userService, impersonationService)
 
 
 	todoController := controllers.NewTodoController(todoService)
-	authController := controllers.NewAuthController(userService)
+	authController := controllers.NewAuthController(userService, tokenService, ssoService)
+	adminController := controllers.NewAdminController(backupService, retentionService, userService, authorizer)
+	keysController := controllers.NewKeysController(keys)
+	apiKeyController := controllers.NewAPIKeyController(apiKeyService)
+	mfaController := controllers.NewMFAController(userService)
+	accountController := controllers.NewAccountController(userService, resetService)
+	sessionController := controllers.NewSessionController(tokenService)
+	impersonationController := controllers.NewImpersonationController(impersonationService)
+	organizationController := controllers.NewOrganizationController(organizationService)
 
 	http.HandleFunc("/api/login", authController.Login)
+	http.HandleFunc("/api/login/mfa", authController.LoginMFA)
+	http.HandleFunc("/api/register", authController.Register)
+	http.HandleFunc("/api/token/refresh", authController.Refresh)
+	http.HandleFunc("/api/oidc/login", authController.SSOLogin)
+	http.HandleFunc("/api/oidc/callback", authController.SSOCallback)
+	http.HandleFunc("/api/password/forgot", accountController.ForgotPassword)
+	http.HandleFunc("/api/password/reset", accountController.ResetPassword)
+	http.HandleFunc("/.well-known/jwks.json", keysController.JWKS)
+	http.Handle("/api/logout", auth.AuthMiddleware(http.HandlerFunc(authController.Logout)))
+	http.Handle("/api/api-keys", auth.AuthMiddleware(http.HandlerFunc(apiKeyController.APIKeys)))
+	http.Handle("/api/account/email", auth.AuthMiddleware(http.HandlerFunc(accountController.Email)))
+	http.Handle("/api/org", auth.AuthMiddleware(http.HandlerFunc(organizationController.Organization)))
+	http.Handle("/api/sessions", auth.AuthMiddleware(http.HandlerFunc(sessionController.Sessions)))
+	http.Handle("/api/sessions/{id}", auth.AuthMiddleware(http.HandlerFunc(sessionController.RevokeSession)))
+	http.Handle("/api/mfa/totp", auth.AuthMiddleware(http.HandlerFunc(mfaController.TOTP)))
+	http.Handle("/api/mfa/totp/verify", auth.AuthMiddleware(http.HandlerFunc(mfaController.ConfirmTOTP)))
+	http.Handle("/api/mfa/recovery-codes", auth.AuthMiddleware(http.HandlerFunc(mfaController.RecoveryCodes)))
 
 
 	todoListMux := http.NewServeMux()
@@ -54,9 +139,73 @@ func main() {
 		}
 	})
 
-	http.Handle("/api/todo-lists", middleware.AuthMiddleware(todoListMux))
-	http.Handle("/api/todo-items", middleware.AuthMiddleware(todoItemMux))
+	http.Handle("/api/todo-lists", auth.AuthMiddleware(todoListMux))
+	http.Handle("/api/todo-lists/members", auth.AuthMiddleware(http.HandlerFunc(todoController.ListMembers)))
+	http.Handle("/api/todo-items", auth.AuthMiddleware(todoItemMux))
+
+	http.Handle("/api/trash", auth.AuthMiddleware(http.HandlerFunc(todoController.GetTrash)))
+	http.Handle("/api/trash/restore-list", auth.AuthMiddleware(http.HandlerFunc(todoController.RestoreTodoList)))
+	http.Handle("/api/trash/restore-item", auth.AuthMiddleware(http.HandlerFunc(todoController.RestoreTodoItem)))
+
+	http.Handle("/api/admin/snapshots", auth.AuthMiddleware(http.HandlerFunc(adminController.Snapshots)))
+	http.Handle("/api/admin/snapshots/restore", auth.AuthMiddleware(http.HandlerFunc(adminController.RestoreSnapshot)))
+	http.Handle("/api/admin/purge", auth.AuthMiddleware(http.HandlerFunc(adminController.Purge)))
+	http.Handle("/api/admin/users", auth.AuthMiddleware(http.HandlerFunc(adminController.Users)))
+	http.Handle("/api/admin/users/password", auth.AuthMiddleware(http.HandlerFunc(adminController.ResetPassword)))
+	http.Handle("/api/admin/users/deactivate", auth.AuthMiddleware(http.HandlerFunc(adminController.DeactivateUser)))
+	http.Handle("/api/admin/users/reactivate", auth.AuthMiddleware(http.HandlerFunc(adminController.ReactivateUser)))
+	http.Handle("/api/admin/users/unlock", auth.AuthMiddleware(http.HandlerFunc(adminController.UnlockUser)))
+	http.Handle("/api/admin/users/reset-mfa", auth.AuthMiddleware(http.HandlerFunc(adminController.ResetMFA)))
+	http.Handle("/api/admin/impersonate", auth.AuthMiddleware(http.HandlerFunc(impersonationController.Impersonate)))
+	http.Handle("/api/admin/audit", auth.AuthMiddleware(http.HandlerFunc(impersonationController.AuditLog)))
+	http.Handle("/api/admin/orgs", auth.AuthMiddleware(http.HandlerFunc(organizationController.Organizations)))
+
+	fmt.Printf("Server is running on %s (%s store)...\n", cfg.Addr, cfg.Store)
+	log.Fatal(http.ListenAndServe(cfg.Addr, nil))
+}
+
+func openStore(cfg *config.Config) (store.Repository, error) {
+	if cfg.Store == config.StoreSQLite {
+		return store.NewSQLiteStore(cfg.SQLitePath)
+	}
+	return openFileStore(cfg)
+}
+
+func openFileStore(cfg *config.Config) (*store.Store, error) {
+	var opts []store.Option
+	if cfg.BackupDir != "" {
+		opts = append(opts, store.WithBackups(cfg.BackupDir, cfg.BackupKeep))
+	}
+	return store.NewFileStore(cfg.JSONPath, opts...)
+}
+
+func openMailer(cfg *config.Config) (mail.Mailer, error) {
+	if cfg.SMTPAddr != "" {
+		return mail.NewSMTPMailer(cfg.SMTPAddr, cfg.MailFrom, cfg.SMTPUsername, cfg.SMTPPassword), nil
+	}
+	return mail.NewOutbox(cfg.MailOutboxDir, cfg.MailFrom)
+}
 
-	fmt.Println("Server is running on port 8080...")
-	http.ListenAndServe(":8080", nil)
+// openSSO returns nil without an OpenID Connect issuer.
+func openSSO(cfg *config.Config, repo store.Repository, authorizer *authz.Authorizer, keys *middleware.KeySet) (*services.SSOService, error) {
+	if cfg.OIDCIssuer == "" {
+		return nil, nil
+	}
+	mappings, err := services.ParseRoleMappings(cfg.OIDCRoles)
+	if err != nil {
+		return nil, err
+	}
+	provider := oidc.NewProvider(oidc.Config{
+		Issuer:       cfg.OIDCIssuer,
+		ClientID:     cfg.OIDCClientID,
+		ClientSecret: cfg.OIDCClientSecret,
+		RedirectURL:  cfg.OIDCRedirectURL,
+		Scopes:       strings.Fields(cfg.OIDCScopes),
+	})
+	return services.NewSSOService(repo, authorizer, keys, provider, cfg.OIDCRedirectURL, services.SSOConfig{
+		RoleClaim:    cfg.OIDCRoleClaim,
+		RoleMappings: mappings,
+		DefaultRole:  cfg.OIDCDefaultRole,
+		Provision:    cfg.OIDCProvision,
+	})
 }
@@ -2,55 +2,150 @@ package middleware
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtKey = []byte("deneme")
+// APIKeyPrefix starts every API key, which is how AuthMiddleware tells them
+// apart from access tokens in the Authorization header.
+const APIKeyPrefix = "tdk_"
 
+// AccessAudience is the audience of access tokens. Challenge, password
+// reset and SSO state tokens are signed with the same keys, so whoever
+// verifies tokens with the published keys has to require it.
+const AccessAudience = "todo-v2"
+
+// Claims are carried by access tokens. OrgID is the user's organization,
+// the tenant every request is confined to. SessionID is the refresh token
+// family the token was issued for; revoking the family revokes the token.
+// Requests made with an API key get the same claims without a session, and
+// with APIKeyID and ReadOnly set instead.
+//
+// Actor is set on impersonation tokens, which let an admin act as the user
+// in UserID. Their SessionID is the admin's own session, so logging the
+// admin out ends the impersonation too.
 type Claims struct {
+	UserID    int    `json:"user_id"`
+	OrgID     int    `json:"org_id"`
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid"`
+	Actor     *Actor `json:"act,omitempty"`
+	APIKeyID  int    `json:"-"`
+	ReadOnly  bool   `json:"-"`
+	jwt.RegisteredClaims
+}
+
+// Actor is who really makes the requests of an impersonation token, after
+// the act claim of RFC 8693.
+type Actor struct {
 	UserID   int    `json:"user_id"`
 	Username string `json:"username"`
-	Role     string `json:"role"`
-	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID int, username, role string) (string, error) {
-	claims := &Claims{
-		UserID:   userID,
-		Username: username,
-		Role:     role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+// GenerateToken issues an access token that expires after ttl.
+func (ks *KeySet) GenerateToken(userID, orgID int, username, role, sessionID string, ttl time.Duration) (string, error) {
+	return ks.generateToken(&Claims{
+		UserID:    userID,
+		OrgID:     orgID,
+		Username:  username,
+		Role:      role,
+		SessionID: sessionID,
+	}, ttl)
+}
+
+// GenerateImpersonationToken issues an access token for actor to act as
+// the user. It belongs to the actor's session.
+func (ks *KeySet) GenerateImpersonationToken(userID, orgID int, username, role string, actor Actor, sessionID string, ttl time.Duration) (string, error) {
+	return ks.generateToken(&Claims{
+		UserID:    userID,
+		OrgID:     orgID,
+		Username:  username,
+		Role:      role,
+		SessionID: sessionID,
+		Actor:     &actor,
+	}, ttl)
+}
+
+func (ks *KeySet) generateToken(claims *Claims, ttl time.Duration) (string, error) {
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtKey)
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        hex.EncodeToString(jti),
+		Audience:  jwt.ClaimStrings{AccessAudience},
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		IssuedAt:  jwt.NewNumericDate(now),
+	}
+	return ks.Sign(claims)
 }
 
-func ValidateToken(tokenString string) (*Claims, error) {
+// ValidateToken verifies an access token. Other tokens signed with the same
+// keys are rejected by their audience.
+func (ks *KeySet) ValidateToken(tokenString string) (*Claims, error) {
 	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return jwtKey, nil
-	})
-
-	if err != nil {
+	if err := ks.Parse(tokenString, claims); err != nil {
 		return nil, err
 	}
-
-	if !token.Valid {
-		return nil, jwt.ErrSignatureInvalid
+	if !slices.Contains(claims.Audience, AccessAudience) {
+		return nil, jwt.ErrTokenInvalidAudience
 	}
-
 	return claims, nil
 }
 
-func AuthMiddleware(next http.Handler) http.Handler {
+// SessionChecker tells whether a login session is still valid, that is not
+// revoked by logging out or from the session list, and notes that it was
+// just used.
+type SessionChecker interface {
+	CheckSession(sessionID string) (active bool, err error)
+}
+
+// AccountChecker looks up the current state of an account, so that
+// deactivations and role changes apply to tokens issued before them.
+type AccountChecker interface {
+	CheckAccount(userID int) (role string, active bool, err error)
+}
+
+// AuditLogger records the requests made with impersonation tokens. action
+// is the request method and URI, status the response status.
+type AuditLogger interface {
+	LogImpersonation(claims *Claims, action, ip string, status int) error
+}
+
+// ImpersonationHeader is set on every response to a request made with an
+// impersonation token and names the admin behind it.
+const ImpersonationHeader = "X-Impersonated-By"
+
+// APIKeyAuthenticator resolves an API key to the claims of its owner.
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(key string) (*Claims, error)
+}
+
+// Authenticator checks the bearer token or API key of incoming requests.
+type Authenticator struct {
+	keys     *KeySet
+	sessions SessionChecker
+	apiKeys  APIKeyAuthenticator
+	accounts AccountChecker
+	audit    AuditLogger
+}
+
+func NewAuthenticator(keys *KeySet, sessions SessionChecker, apiKeys APIKeyAuthenticator, accounts AccountChecker, audit AuditLogger) *Authenticator {
+	return &Authenticator{keys: keys, sessions: sessions, apiKeys: apiKeys, accounts: accounts, audit: audit}
+}
+
+func (a *Authenticator) AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
@@ -64,13 +159,93 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		claims, err := ValidateToken(parts[1])
+		if strings.HasPrefix(parts[1], APIKeyPrefix) {
+			a.serveAPIKey(w, r, next, parts[1])
+			return
+		}
+
+		claims, err := a.keys.ValidateToken(parts[1])
 		if err != nil {
 			http.Error(w, "Invalid token", http.StatusUnauthorized)
 			return
 		}
 
+		// Tokens without a session predate revocation and cannot be
+		// revoked, and tokens without an organization are not confined to
+		// one, so neither is accepted.
+		if claims.SessionID == "" || claims.OrgID == 0 {
+			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			return
+		}
+		active, err := a.sessions.CheckSession(claims.SessionID)
+		if err != nil {
+			http.Error(w, "Error checking token", http.StatusInternalServerError)
+			return
+		}
+		if !active {
+			http.Error(w, "Token has been revoked", http.StatusUnauthorized)
+			return
+		}
+
+		role, active, err := a.accounts.CheckAccount(claims.UserID)
+		if err != nil {
+			http.Error(w, "Error checking token", http.StatusInternalServerError)
+			return
+		}
+		if !active {
+			http.Error(w, "Account is no longer active", http.StatusUnauthorized)
+			return
+		}
+		claims.Role = role
+
 		ctx := context.WithValue(r.Context(), "claims", claims)
+		if claims.Actor != nil {
+			a.serveImpersonated(w, r.WithContext(ctx), next, claims)
+			return
+		}
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
-} 
\ No newline at end of file
+}
+
+// serveImpersonated marks the response as made under impersonation and
+// records the request in the audit log once it has been answered.
+func (a *Authenticator) serveImpersonated(w http.ResponseWriter, r *http.Request, next http.Handler, claims *Claims) {
+	w.Header().Set(ImpersonationHeader, claims.Actor.Username)
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	next.ServeHTTP(rec, r)
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	action := r.Method + " " + r.URL.RequestURI()
+	if err := a.audit.LogImpersonation(claims, action, ip, rec.status); err != nil {
+		log.Printf("failed to audit %s by %s as %s: %v", action, claims.Actor.Username, claims.Username, err)
+	}
+}
+
+// statusRecorder remembers the status code a handler answered with.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (a *Authenticator) serveAPIKey(w http.ResponseWriter, r *http.Request, next http.Handler, key string) {
+	claims, err := a.apiKeys.AuthenticateAPIKey(key)
+	if err != nil {
+		http.Error(w, "Invalid API key", http.StatusUnauthorized)
+		return
+	}
+	if claims.ReadOnly && r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "API key is read-only", http.StatusForbidden)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), "claims", claims)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
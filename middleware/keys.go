@@ -0,0 +1,290 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// KeyConfig says where the token signing keys come from.
+//
+// Dir holds one file per key, and the file name without its extension is
+// the key ID (kid):
+//   - *.pem with a PKCS#8 or PKCS#1 private key signs and verifies with
+//     RS256 (RSA) or EdDSA (Ed25519),
+//   - *.pem with only a public key verifies tokens but never signs, which
+//     is how a retired key stays valid until its tokens expire,
+//   - *.secret holds a shared HS256 secret.
+//
+// Secret adds an HS256 key with the ID "default". SigningKeyID picks the key
+// new tokens are signed with. It defaults to the last private key by ID,
+// with RSA and Ed25519 keys taking precedence over HS256 secrets, so naming
+// keys by date rotates them by dropping a new file in.
+type KeyConfig struct {
+	Dir          string
+	Secret       string
+	SigningKeyID string
+}
+
+type signingKey struct {
+	id     string
+	method jwt.SigningMethod
+	sign   interface{} // nil for verify-only keys
+	verify interface{}
+}
+
+// KeySet signs access tokens with one key and verifies them with any of
+// the keys it knows, matched by the kid header.
+type KeySet struct {
+	signing *signingKey
+	keys    map[string]*signingKey
+}
+
+// LoadKeySet reads the keys described by cfg. Without any keys it generates
+// a throwaway Ed25519 key, so access tokens do not survive a restart;
+// clients get new ones with their refresh token.
+func LoadKeySet(cfg KeyConfig) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]*signingKey)}
+	if cfg.Dir != "" {
+		if err := ks.loadDir(cfg.Dir); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Secret != "" {
+		if err := ks.add(hmacKey("default", []byte(cfg.Secret))); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(ks.keys) == 0 {
+		log.Printf("no JWT signing keys configured, using a temporary Ed25519 key")
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		key, err := privateKey("temporary", priv)
+		if err != nil {
+			return nil, err
+		}
+		ks.add(key)
+	}
+
+	if cfg.SigningKeyID != "" {
+		key, ok := ks.keys[cfg.SigningKeyID]
+		if !ok {
+			return nil, fmt.Errorf("signing key %q not found", cfg.SigningKeyID)
+		}
+		if key.sign == nil {
+			return nil, fmt.Errorf("signing key %q has no private key", cfg.SigningKeyID)
+		}
+		ks.signing = key
+		return ks, nil
+	}
+
+	ids := make([]string, 0, len(ks.keys))
+	for id := range ks.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		key := ks.keys[id]
+		if key.sign == nil {
+			continue
+		}
+		_, secret := key.sign.([]byte)
+		if ks.signing == nil || !secret || ks.signing.method == key.method {
+			ks.signing = key
+		}
+	}
+	if ks.signing == nil {
+		return nil, errors.New("no private key to sign tokens with")
+	}
+	return ks, nil
+}
+
+func (ks *KeySet) loadDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read key directory: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		ext := filepath.Ext(entry.Name())
+		id := strings.TrimSuffix(entry.Name(), ext)
+		if ext != ".pem" && ext != ".secret" {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to read key %s: %w", entry.Name(), err)
+		}
+		var key *signingKey
+		if ext == ".secret" {
+			key = hmacKey(id, []byte(strings.TrimSpace(string(data))))
+		} else if key, err = pemKey(id, data); err != nil {
+			return fmt.Errorf("key %s: %w", entry.Name(), err)
+		}
+		if err := ks.add(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ks *KeySet) add(key *signingKey) error {
+	if _, exists := ks.keys[key.id]; exists {
+		return fmt.Errorf("duplicate key ID %q", key.id)
+	}
+	ks.keys[key.id] = key
+	return nil
+}
+
+// Sign signs claims with the active key and names it in the kid header.
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.signing.method, claims)
+	token.Header["kid"] = ks.signing.id
+	return token.SignedString(ks.signing.sign)
+}
+
+// Parse verifies tokenString with the key named in its kid header. The
+// token's algorithm has to be the key's, so a public key can never be
+// used as an HMAC secret.
+func (ks *KeySet) Parse(tokenString string, claims jwt.Claims) error {
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := ks.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key ID %q", kid)
+		}
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("key %q does not use %s", kid, token.Method.Alg())
+		}
+		return key.verify, nil
+	})
+	if err != nil {
+		return err
+	}
+	if !token.Valid {
+		return jwt.ErrSignatureInvalid
+	}
+	return nil
+}
+
+// JWK is a public key in JSON Web Key format.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+// JWKS returns the public keys other services need to verify our tokens.
+// HS256 secrets are shared out of band and never published.
+func (ks *KeySet) JWKS() []JWK {
+	ids := make([]string, 0, len(ks.keys))
+	for id := range ks.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	keys := make([]JWK, 0, len(ids))
+	for _, id := range ids {
+		key := ks.keys[id]
+		jwk := JWK{KeyID: id, Algorithm: key.method.Alg(), Use: "sig"}
+		switch pub := key.verify.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		keys = append(keys, jwk)
+	}
+	return keys
+}
+
+func hmacKey(id string, secret []byte) *signingKey {
+	return &signingKey{id: id, method: jwt.SigningMethodHS256, sign: secret, verify: secret}
+}
+
+func pemKey(id string, data []byte) (*signingKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	switch block.Type {
+	case "PRIVATE KEY":
+		priv, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return privateKey(id, priv)
+	case "RSA PRIVATE KEY":
+		priv, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return privateKey(id, priv)
+	case "PUBLIC KEY":
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return publicKey(id, pub)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+}
+
+func privateKey(id string, priv interface{}) (*signingKey, error) {
+	signer, ok := priv.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported private key")
+	}
+	key, err := publicKey(id, signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	key.sign = priv
+	return key, nil
+}
+
+func publicKey(id string, pub interface{}) (*signingKey, error) {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("RSA key is %d bits, at least 2048 are required", pub.N.BitLen())
+		}
+		return &signingKey{id: id, method: jwt.SigningMethodRS256, verify: pub}, nil
+	case ed25519.PublicKey:
+		return &signingKey{id: id, method: jwt.SigningMethodEdDSA, verify: pub}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, expected RSA or Ed25519", pub)
+	}
+}
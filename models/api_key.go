@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// APIKey lets scripts authenticate as a user without a password. Only a
+// hash of the key is stored; Prefix is the start of the key, kept so users
+// can tell their keys apart.
+type APIKey struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Name      string    `json:"name"`
+	Prefix    string    `json:"prefix"`
+	KeyHash   string    `json:"key_hash"`
+	ReadOnly  bool      `json:"read_only"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	RevokedAt time.Time `json:"revoked_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// AuditEntry records something an admin did while impersonating a user:
+// starting the impersonation, or one request made with the token. Action
+// is either a description or the request method and URI, and Status is
+// the response status of a request. OrgID is the organization of the
+// user, which is also the admin's.
+type AuditEntry struct {
+	ID        int       `json:"id"`
+	OrgID     int       `json:"org_id"`
+	ActorID   int       `json:"actor_id"`
+	UserID    int       `json:"user_id"`
+	Action    string    `json:"action"`
+	Status    int       `json:"status,omitempty"`
+	IP        string    `json:"ip"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Organization is a tenant. Every user and every list belongs to exactly
+// one, and nothing is shared or visible across organizations.
+type Organization struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// DefaultOrganizationID is the organization that self-registered users
+// join, and that accounts and lists from before organizations existed were
+// moved into.
+const DefaultOrganizationID = 1
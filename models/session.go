@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Session describes a login and the device it came from. Its ID is the
+// refresh token family started by the login, which is also the sid claim
+// of the session's access tokens. Whether the session is still valid is
+// decided by its refresh tokens; ExpiresAt follows the newest of them.
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     int       `json:"user_id"`
+	Device     string    `json:"device"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
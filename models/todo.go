@@ -2,15 +2,33 @@ package models
 
 import "time"
 
+// TodoList belongs to the organization OrgID, that of the user who created
+// it. Its owner and members are always in the same organization.
 type TodoList struct {
-	ID                   int        `json:"id"`
-	Name                 string     `json:"name"`
-	CreatedAt            time.Time  `json:"created_at"`
-	UpdatedAt            time.Time  `json:"updated_at"`
-	DeletedAt            time.Time  `json:"deleted_at"`
-	CompletionPercentage int        `json:"completion_percentage"`
-	TodoItems            []TodoItem `json:"todo_items"`
-	UserID               int        `json:"user_id"`
+	ID                   int          `json:"id"`
+	OrgID                int          `json:"org_id"`
+	Name                 string       `json:"name"`
+	CreatedAt            time.Time    `json:"created_at"`
+	UpdatedAt            time.Time    `json:"updated_at"`
+	DeletedAt            time.Time    `json:"deleted_at"`
+	CompletionPercentage int          `json:"completion_percentage"`
+	TodoItems            []TodoItem   `json:"todo_items"`
+	UserID               int          `json:"user_id"`
+	Members              []ListMember `json:"members,omitempty"`
+}
+
+// Roles a collaborator can have on a list. The list's UserID is always its
+// owner; Members holds everyone else it is shared with.
+const (
+	ListOwner  = "owner"
+	ListEditor = "editor"
+	ListViewer = "viewer"
+)
+
+type ListMember struct {
+	UserID  int       `json:"user_id"`
+	Role    string    `json:"role"`
+	AddedAt time.Time `json:"added_at"`
 }
 
 type TodoItem struct {
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// RefreshToken is a long-lived token that can be traded for a new access
+// token once. Only a hash of the token is stored. Every refresh replaces the
+// token with a new one from the same family; the family is what a login
+// session is revoked by.
+type RefreshToken struct {
+	TokenHash string    `json:"token_hash"`
+	FamilyID  string    `json:"family_id"`
+	UserID    int       `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	UsedAt    time.Time `json:"used_at"`
+	RevokedAt time.Time `json:"revoked_at"`
+}
@@ -1,8 +1,36 @@
 package models
 
+import "time"
+
+// User is an account of the organization OrgID. A deactivated user cannot
+// log in, and tokens issued before the deactivation stop working. Email is
+// optional and only used to send password reset links.
+//
+// TOTPSecret is set when the user starts enrolling a second factor, which
+// only applies to logins once TOTPEnabled. TOTPLastStep is the time step of
+// the last accepted code, so a code cannot be used twice. RecoveryCodes
+// holds the hashes of the unused recovery codes.
+//
+// PasswordVersion goes up with every password change. Password reset
+// tokens name the version they were issued for, so they work only once and
+// not at all after the password has changed otherwise.
+//
+// SSOIssuer and SSOSubject link the account to a user at an OpenID Connect
+// provider, who logs in there instead of with a password. Accounts created
+// on their first SSO login have no password at all.
 type User struct {
-	ID       int    `json:"id"`
-	Username string `json:"username"`
-	Password string `json:"password"`
-	Role     string `json:"role"`
+	ID              int       `json:"id"`
+	OrgID           int       `json:"org_id"`
+	Username        string    `json:"username"`
+	Password        string    `json:"password"`
+	PasswordVersion int       `json:"password_version,omitempty"`
+	Role            string    `json:"role"`
+	Email           string    `json:"email,omitempty"`
+	DeactivatedAt   time.Time `json:"deactivated_at"`
+	TOTPSecret      string    `json:"totp_secret,omitempty"`
+	TOTPEnabled     bool      `json:"totp_enabled,omitempty"`
+	TOTPLastStep    int64     `json:"totp_last_step,omitempty"`
+	RecoveryCodes   []string  `json:"recovery_codes,omitempty"`
+	SSOIssuer       string    `json:"sso_issuer,omitempty"`
+	SSOSubject      string    `json:"sso_subject,omitempty"`
 }
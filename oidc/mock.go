@@ -0,0 +1,252 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YahyaCengiz/todo-v2/middleware"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	mockKeyID    = "mock"
+	mockCodeTTL  = time.Minute
+	mockTokenTTL = 5 * time.Minute
+)
+
+// MockUser is an account at the mock provider. Its subject is "mock-"
+// followed by the username.
+type MockUser struct {
+	Username string
+	Email    string
+	Groups   []string
+}
+
+// MockProvider is a minimal OpenID Connect provider for development and
+// for trying the SSO login without a real one. It logs users in without
+// asking for anything: the authorization endpoint picks the user named by
+// the login_hint parameter, or the first one, and redirects straight back
+// with a code. It does check the client, redirect URI and PKCE like a real
+// provider would. Never expose it to real users.
+type MockProvider struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	users        []MockUser
+	key          *rsa.PrivateKey
+	mux          *http.ServeMux
+
+	mu    sync.Mutex
+	codes map[string]mockCode
+}
+
+type mockCode struct {
+	user        MockUser
+	redirectURI string
+	nonce       string
+	challenge   string
+	expiresAt   time.Time
+}
+
+// NewMockProvider returns a provider with issuer as its base URL and a
+// freshly generated signing key.
+func NewMockProvider(issuer, clientID, clientSecret string, users []MockUser) (*MockProvider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	m := &MockProvider{
+		issuer:       strings.TrimSuffix(issuer, "/"),
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		users:        users,
+		key:          key,
+		mux:          http.NewServeMux(),
+		codes:        make(map[string]mockCode),
+	}
+	m.mux.HandleFunc("/.well-known/openid-configuration", m.discovery)
+	m.mux.HandleFunc("/authorize", m.authorize)
+	m.mux.HandleFunc("/token", m.token)
+	m.mux.HandleFunc("/jwks", m.jwks)
+	return m, nil
+}
+
+func (m *MockProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mux.ServeHTTP(w, r)
+}
+
+func (m *MockProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                m.issuer,
+		"authorization_endpoint":                m.issuer + "/authorize",
+		"token_endpoint":                        m.issuer + "/token",
+		"jwks_uri":                              m.issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"scopes_supported":                      []string{"openid", "profile", "email", "groups"},
+	})
+}
+
+func (m *MockProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := &m.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []middleware.JWK{{
+			KeyType:   "RSA",
+			KeyID:     mockKeyID,
+			Algorithm: jwt.SigningMethodRS256.Alg(),
+			Use:       "sig",
+			N:         base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+// authorize logs the user in at once. Errors that make the redirect URI
+// untrustworthy are shown here instead of being sent back to it.
+func (m *MockProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	redirectURI := query.Get("redirect_uri")
+	if query.Get("client_id") != m.clientID || redirectURI == "" {
+		http.Error(w, "unknown client or missing redirect_uri", http.StatusBadRequest)
+		return
+	}
+	callback, err := url.Parse(redirectURI)
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	params := callback.Query()
+	params.Set("state", query.Get("state"))
+	switch {
+	case query.Get("response_type") != "code":
+		params.Set("error", "unsupported_response_type")
+	case !hasScope(query.Get("scope"), "openid"):
+		params.Set("error", "invalid_scope")
+	case query.Get("code_challenge") == "" || query.Get("code_challenge_method") != "S256":
+		params.Set("error", "invalid_request")
+	case len(m.users) == 0:
+		params.Set("error", "access_denied")
+	default:
+		user := m.users[0]
+		for _, u := range m.users {
+			if u.Username == query.Get("login_hint") {
+				user = u
+			}
+		}
+		code, err := NewVerifier()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		m.mu.Lock()
+		m.codes[code] = mockCode{
+			user:        user,
+			redirectURI: redirectURI,
+			nonce:       query.Get("nonce"),
+			challenge:   query.Get("code_challenge"),
+			expiresAt:   time.Now().Add(mockCodeTTL),
+		}
+		m.mu.Unlock()
+		params.Set("code", code)
+	}
+	callback.RawQuery = params.Encode()
+	http.Redirect(w, r, callback.String(), http.StatusFound)
+}
+
+func (m *MockProvider) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, http.StatusBadRequest, "invalid_request")
+		return
+	}
+
+	clientID, secret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientID, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != m.clientID || subtle.ConstantTimeCompare([]byte(secret), []byte(m.clientSecret)) != 1 {
+		tokenError(w, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, http.StatusBadRequest, "unsupported_grant_type")
+		return
+	}
+
+	// Codes work once, even when the request fails.
+	m.mu.Lock()
+	code, ok := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	m.mu.Unlock()
+	if !ok || time.Now().After(code.expiresAt) || code.redirectURI != r.PostForm.Get("redirect_uri") ||
+		Challenge(r.PostForm.Get("code_verifier")) != code.challenge {
+		tokenError(w, http.StatusBadRequest, "invalid_grant")
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":                m.issuer,
+		"sub":                "mock-" + code.user.Username,
+		"aud":                m.clientID,
+		"exp":                now.Add(mockTokenTTL).Unix(),
+		"iat":                now.Unix(),
+		"preferred_username": code.user.Username,
+		"groups":             code.user.Groups,
+	}
+	if code.nonce != "" {
+		claims["nonce"] = code.nonce
+	}
+	if code.user.Email != "" {
+		claims["email"] = code.user.Email
+		claims["email_verified"] = true
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = mockKeyID
+	idToken, err := token.SignedString(m.key)
+	if err != nil {
+		tokenError(w, http.StatusInternalServerError, "server_error")
+		return
+	}
+	accessToken, err := NewVerifier()
+	if err != nil {
+		tokenError(w, http.StatusInternalServerError, "server_error")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(mockTokenTTL / time.Second),
+		"id_token":     idToken,
+	})
+}
+
+func tokenError(w http.ResponseWriter, status int, code string) {
+	writeJSON(w, status, map[string]interface{}{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/YahyaCengiz/todo-v2/authz"
+	"github.com/YahyaCengiz/todo-v2/middleware"
+	"github.com/YahyaCengiz/todo-v2/models"
+	"github.com/YahyaCengiz/todo-v2/store"
+)
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrInvalidAPIKey  = errors.New("invalid api key")
+)
+
+const maxAPIKeyNameLength = 64
+
+// apiKeyPrefixLength is how much of a key is kept in the clear: the "tdk_"
+// marker and a few random characters.
+const apiKeyPrefixLength = len(middleware.APIKeyPrefix) + 8
+
+// APIKeyService manages the API keys users create for scripts and CI.
+type APIKeyService struct {
+	store store.Repository
+	authz *authz.Authorizer
+}
+
+func NewAPIKeyService(store store.Repository, authorizer *authz.Authorizer) *APIKeyService {
+	return &APIKeyService{store: store, authz: authorizer}
+}
+
+// CreateAPIKey stores a new key for the user and returns it together with
+// the key itself, which is not stored and cannot be shown again. A zero
+// expiresAt creates a key that does not expire.
+func (s *APIKeyService) CreateAPIKey(sub authz.Subject, name string, readOnly bool, expiresAt time.Time) (*models.APIKey, string, error) {
+	if err := s.authz.Authorize(sub, authz.APIKeysManage); err != nil {
+		return nil, "", err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxAPIKeyNameLength {
+		return nil, "", fmt.Errorf("%w: name must be 1 to %d characters", ErrInvalidInput, maxAPIKeyNameLength)
+	}
+	now := time.Now()
+	if !expiresAt.IsZero() && !expiresAt.After(now) {
+		return nil, "", fmt.Errorf("%w: expires_at must be in the future", ErrInvalidInput)
+	}
+
+	secret, err := randomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	plaintext := middleware.APIKeyPrefix + secret
+	key := &models.APIKey{
+		UserID:    sub.UserID,
+		Name:      name,
+		Prefix:    plaintext[:apiKeyPrefixLength],
+		KeyHash:   hashToken(plaintext),
+		ReadOnly:  readOnly,
+		CreatedAt: now,
+		ExpiresAt: expiresAt,
+	}
+	if err := s.store.CreateAPIKey(key); err != nil {
+		return nil, "", err
+	}
+	return key, plaintext, nil
+}
+
+func (s *APIKeyService) ListAPIKeys(userID int) ([]models.APIKey, error) {
+	return s.store.GetAPIKeysByUser(userID)
+}
+
+// RevokeAPIKey revokes one of the subject's keys, or anyone's in its
+// organization with api_keys:manage:any. Revoking an already revoked key
+// is not an error.
+func (s *APIKeyService) RevokeAPIKey(id int, sub authz.Subject) error {
+	return s.store.Tx(func(tx store.Repository) error {
+		key, err := tx.GetAPIKey(id)
+		if errors.Is(err, store.ErrAPIKeyNotFound) {
+			return ErrAPIKeyNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if !s.authz.CanOn(sub, authz.APIKeysManage, key.UserID) {
+			return ErrAPIKeyNotFound
+		}
+		if owner, err := tx.GetUserByID(key.UserID); err != nil || owner.OrgID != sub.OrgID {
+			return ErrAPIKeyNotFound
+		}
+		if !key.RevokedAt.IsZero() {
+			return nil
+		}
+		key.RevokedAt = time.Now()
+		return tx.UpdateAPIKey(key)
+	})
+}
+
+// AuthenticateAPIKey implements middleware.APIKeyAuthenticator. The claims
+// carry the owner's current username and role.
+func (s *APIKeyService) AuthenticateAPIKey(plaintext string) (*middleware.Claims, error) {
+	key, err := s.store.GetAPIKeyByHash(hashToken(plaintext))
+	if err != nil {
+		return nil, ErrInvalidAPIKey
+	}
+	if !key.RevokedAt.IsZero() || (!key.ExpiresAt.IsZero() && time.Now().After(key.ExpiresAt)) {
+		return nil, ErrInvalidAPIKey
+	}
+	user, err := s.store.GetUserByID(key.UserID)
+	if err != nil || !user.DeactivatedAt.IsZero() {
+		return nil, ErrInvalidAPIKey
+	}
+	return &middleware.Claims{
+		UserID:   user.ID,
+		OrgID:    user.OrgID,
+		Username: user.Username,
+		Role:     user.Role,
+		APIKeyID: key.ID,
+		ReadOnly: key.ReadOnly,
+	}, nil
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/YahyaCengiz/todo-v2/store"
+)
+
+var ErrBackupsUnsupported = errors.New("the configured store does not support snapshots")
+
+type BackupService struct {
+	snapshots store.Snapshotter
+}
+
+// NewBackupService wraps the snapshot support of repo. Backends without it
+// report ErrBackupsUnsupported from every method.
+func NewBackupService(repo store.Repository) *BackupService {
+	snapshots, _ := repo.(store.Snapshotter)
+	return &BackupService{snapshots: snapshots}
+}
+
+// Run takes a snapshot every interval until the process exits.
+func (s *BackupService) Run(interval time.Duration) {
+	if s.snapshots == nil || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if _, err := s.snapshots.Snapshot(); err != nil {
+			if errors.Is(err, store.ErrBackupsDisabled) {
+				return
+			}
+			log.Printf("scheduled snapshot failed: %v", err)
+		}
+	}
+}
+
+func (s *BackupService) ListSnapshots() ([]store.SnapshotInfo, error) {
+	if s.snapshots == nil {
+		return nil, ErrBackupsUnsupported
+	}
+	return s.snapshots.ListSnapshots()
+}
+
+func (s *BackupService) CreateSnapshot() (*store.SnapshotInfo, error) {
+	if s.snapshots == nil {
+		return nil, ErrBackupsUnsupported
+	}
+	return s.snapshots.Snapshot()
+}
+
+func (s *BackupService) RestoreSnapshot(name string) (*store.SnapshotInfo, error) {
+	if s.snapshots == nil {
+		return nil, ErrBackupsUnsupported
+	}
+	return s.snapshots.RestoreSnapshot(name)
+}
+
+func (s *BackupService) RestoreToTime(at time.Time) (*store.SnapshotInfo, error) {
+	if s.snapshots == nil {
+		return nil, ErrBackupsUnsupported
+	}
+	return s.snapshots.RestoreToTime(at)
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/YahyaCengiz/todo-v2/authz"
+	"github.com/YahyaCengiz/todo-v2/models"
+	"github.com/YahyaCengiz/todo-v2/store"
+)
+
+var (
+	ErrMemberNotFound = errors.New("list member not found")
+	ErrAlreadyMember  = errors.New("user already has access to the list")
+)
+
+// listGrants are the permissions a role on a list gives on everything in
+// it. They only apply when the subject's own role has the permission too,
+// so sharing a list with a viewer account never lets it write.
+var listGrants = map[string][]authz.Permission{
+	models.ListOwner:  {authz.ListsRead, authz.ListsWrite, authz.ItemsWrite, authz.ItemsComplete},
+	models.ListEditor: {authz.ListsRead, authz.ItemsWrite, authz.ItemsComplete},
+	models.ListViewer: {authz.ListsRead},
+}
+
+// Collaborator is someone with access to a list, as shown to clients.
+type Collaborator struct {
+	UserID   int       `json:"user_id"`
+	Username string    `json:"username"`
+	Role     string    `json:"role"`
+	AddedAt  time.Time `json:"added_at"`
+}
+
+// listRole returns the user's role on the list, or "" if it is not shared
+// with them.
+func listRole(todoList *models.TodoList, userID int) string {
+	if todoList.UserID == userID {
+		return models.ListOwner
+	}
+	for _, member := range todoList.Members {
+		if member.UserID == userID {
+			return member.Role
+		}
+	}
+	return ""
+}
+
+// canOnList reports whether sub may use perm on a record of todoList owned
+// by ownerID, either through its own permissions or its role on the list.
+// Nothing gives access to a list of another organization.
+func (s *TodoService) canOnList(sub authz.Subject, perm authz.Permission, todoList *models.TodoList, ownerID int) bool {
+	if todoList.OrgID != sub.OrgID {
+		return false
+	}
+	if s.authz.CanOn(sub, perm, ownerID) {
+		return true
+	}
+	return slices.Contains(listGrants[listRole(todoList, sub.UserID)], perm) && s.authz.Can(sub, perm)
+}
+
+func (s *TodoService) authorizeOnList(sub authz.Subject, perm authz.Permission, todoList *models.TodoList, ownerID int) error {
+	if !s.canOnList(sub, perm, todoList, ownerID) {
+		return authz.ErrForbidden
+	}
+	return nil
+}
+
+// GetListMembers returns the owner followed by everyone the list is shared
+// with.
+func (s *TodoService) GetListMembers(listID int, sub authz.Subject) ([]Collaborator, error) {
+	todoList, err := s.liveList(s.store, listID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.authorizeOnList(sub, authz.ListsRead, todoList, todoList.UserID); err != nil {
+		return nil, err
+	}
+
+	collaborators := []Collaborator{s.collaborator(s.store, models.ListMember{
+		UserID: todoList.UserID, Role: models.ListOwner, AddedAt: todoList.CreatedAt,
+	})}
+	for _, member := range todoList.Members {
+		collaborators = append(collaborators, s.collaborator(s.store, member))
+	}
+	return collaborators, nil
+}
+
+// AddListMember shares the list with the user. Only those who may change
+// the list itself can share it.
+func (s *TodoService) AddListMember(listID int, username, role string, sub authz.Subject) (*Collaborator, error) {
+	if err := validateListRole(role); err != nil {
+		return nil, err
+	}
+
+	var added Collaborator
+	err := s.store.Tx(func(tx store.Repository) error {
+		todoList, err := s.liveList(tx, listID)
+		if err != nil {
+			return err
+		}
+		if err := s.authorizeOnList(sub, authz.ListsWrite, todoList, todoList.UserID); err != nil {
+			return err
+		}
+		user, err := tx.GetUserByUsername(username)
+		if err != nil || user.OrgID != todoList.OrgID {
+			return store.ErrUserNotFound
+		}
+		if listRole(todoList, user.ID) != "" {
+			return ErrAlreadyMember
+		}
+
+		member := models.ListMember{UserID: user.ID, Role: role, AddedAt: time.Now()}
+		todoList.Members = append(todoList.Members, member)
+		if err := tx.UpdateTodoList(todoList); err != nil {
+			return err
+		}
+		added = Collaborator{UserID: user.ID, Username: user.Username, Role: role, AddedAt: member.AddedAt}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &added, nil
+}
+
+// UpdateListMember changes the role of someone the list is shared with. The
+// list's owner cannot be changed this way.
+func (s *TodoService) UpdateListMember(listID, userID int, role string, sub authz.Subject) (*Collaborator, error) {
+	if err := validateListRole(role); err != nil {
+		return nil, err
+	}
+
+	var updated Collaborator
+	err := s.store.Tx(func(tx store.Repository) error {
+		todoList, err := s.liveList(tx, listID)
+		if err != nil {
+			return err
+		}
+		if err := s.authorizeOnList(sub, authz.ListsWrite, todoList, todoList.UserID); err != nil {
+			return err
+		}
+		k := slices.IndexFunc(todoList.Members, func(m models.ListMember) bool { return m.UserID == userID })
+		if k < 0 {
+			return ErrMemberNotFound
+		}
+
+		todoList.Members[k].Role = role
+		if err := tx.UpdateTodoList(todoList); err != nil {
+			return err
+		}
+		updated = s.collaborator(tx, todoList.Members[k])
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// RemoveListMember stops sharing the list with the user. Members may always
+// remove themselves.
+func (s *TodoService) RemoveListMember(listID, userID int, sub authz.Subject) error {
+	return s.store.Tx(func(tx store.Repository) error {
+		todoList, err := s.liveList(tx, listID)
+		if err != nil {
+			return err
+		}
+		if userID != sub.UserID {
+			if err := s.authorizeOnList(sub, authz.ListsWrite, todoList, todoList.UserID); err != nil {
+				return err
+			}
+		}
+		k := slices.IndexFunc(todoList.Members, func(m models.ListMember) bool { return m.UserID == userID })
+		if k < 0 {
+			return ErrMemberNotFound
+		}
+
+		todoList.Members = slices.Delete(todoList.Members, k, k+1)
+		return tx.UpdateTodoList(todoList)
+	})
+}
+
+// liveList loads a list that has not been deleted.
+func (s *TodoService) liveList(repo store.Repository, listID int) (*models.TodoList, error) {
+	todoList, err := repo.GetTodoList(listID)
+	if err != nil {
+		return nil, err
+	}
+	if !todoList.DeletedAt.IsZero() {
+		return nil, store.ErrListNotFound
+	}
+	return todoList, nil
+}
+
+func (s *TodoService) collaborator(repo store.Repository, member models.ListMember) Collaborator {
+	collaborator := Collaborator{UserID: member.UserID, Role: member.Role, AddedAt: member.AddedAt}
+	if user, err := repo.GetUserByID(member.UserID); err == nil {
+		collaborator.Username = user.Username
+	}
+	return collaborator
+}
+
+func validateListRole(role string) error {
+	switch role {
+	case models.ListOwner, models.ListEditor, models.ListViewer:
+		return nil
+	}
+	return fmt.Errorf("%w: role must be %q, %q or %q", ErrInvalidInput, models.ListOwner, models.ListEditor, models.ListViewer)
+}
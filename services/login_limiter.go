@@ -0,0 +1,167 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// ThrottledError is returned by Login and RequestReset while the username,
+// email address or client address is backing off or locked out.
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return "too many attempts, try again later"
+}
+
+// LoginLimiter slows down password guessing. Every failed login for a
+// username doubles the wait before the next attempt, starting at backoff,
+// and maxFailures failures lock the username for lockout. A client address
+// is locked for lockout after maxIPFailures failures, whatever usernames it
+// tried. Failures are forgotten once lockout has passed without new ones.
+//
+// The state is kept in memory, so a restart clears it.
+type LoginLimiter struct {
+	maxFailures   int
+	maxIPFailures int
+	backoff       time.Duration
+	lockout       time.Duration
+
+	mu    sync.Mutex
+	users map[string]*loginAttempts
+	ips   map[string]*loginAttempts
+}
+
+type loginAttempts struct {
+	failures     int
+	lastFailure  time.Time
+	blockedUntil time.Time
+}
+
+// NewLoginLimiter returns a limiter with the given thresholds. A zero
+// maxFailures or maxIPFailures disables that check.
+func NewLoginLimiter(maxFailures, maxIPFailures int, backoff, lockout time.Duration) *LoginLimiter {
+	return &LoginLimiter{
+		maxFailures:   maxFailures,
+		maxIPFailures: maxIPFailures,
+		backoff:       backoff,
+		lockout:       lockout,
+		users:         make(map[string]*loginAttempts),
+		ips:           make(map[string]*loginAttempts),
+	}
+}
+
+// Begin starts a login attempt. If the attempt is allowed it is counted as
+// a failure right away, so concurrent guesses cannot slip past the limit;
+// Succeed takes it back.
+func (l *LoginLimiter) Begin(username, ip string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	user, addr := l.entry(l.users, username, now), l.entry(l.ips, ip, now)
+	wait := max(user.blockedUntil.Sub(now), addr.blockedUntil.Sub(now))
+	if wait > 0 {
+		return &ThrottledError{RetryAfter: wait}
+	}
+
+	user.failures++
+	user.lastFailure = now
+	if l.maxFailures > 0 {
+		user.blockedUntil = now.Add(l.userDelay(user.failures))
+	}
+	addr.failures++
+	addr.lastFailure = now
+	if l.maxIPFailures > 0 && addr.failures >= l.maxIPFailures {
+		addr.blockedUntil = now.Add(l.lockout)
+	}
+	return nil
+}
+
+// Succeed records that the attempt started by Begin logged in. The
+// username starts over; the address only gets the attempt back, so a valid
+// account cannot be used to reset it.
+func (l *LoginLimiter) Succeed(username, ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.users, username)
+	if addr, ok := l.ips[ip]; ok && addr.failures > 0 {
+		addr.failures--
+	}
+}
+
+// Release takes back the attempt started by Begin but, unlike Succeed,
+// keeps earlier failures of the username. It is for a correct password that
+// still needs a second factor, so that the password cannot be used to wipe
+// out failed codes.
+func (l *LoginLimiter) Release(username, ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if user, ok := l.users[username]; ok && user.failures > 0 {
+		user.failures--
+		if user.failures == 0 {
+			delete(l.users, username)
+		} else {
+			user.blockedUntil = user.lastFailure.Add(l.userDelay(user.failures))
+		}
+	}
+	if addr, ok := l.ips[ip]; ok && addr.failures > 0 {
+		addr.failures--
+	}
+}
+
+// Unlock clears the failures and any lockout of a username.
+func (l *LoginLimiter) Unlock(username string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.users, username)
+}
+
+// Run forgets stale entries every interval until the process exits.
+func (l *LoginLimiter) Run(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		l.mu.Lock()
+		now := time.Now()
+		for _, entries := range []map[string]*loginAttempts{l.users, l.ips} {
+			for key, attempts := range entries {
+				if l.stale(attempts, now) {
+					delete(entries, key)
+				}
+			}
+		}
+		l.mu.Unlock()
+	}
+}
+
+// userDelay is how long a username has to wait after its nth failure.
+func (l *LoginLimiter) userDelay(failures int) time.Duration {
+	if failures >= l.maxFailures {
+		return l.lockout
+	}
+	delay := l.backoff
+	for i := 1; i < failures && delay < l.lockout; i++ {
+		delay *= 2
+	}
+	return min(delay, l.lockout)
+}
+
+func (l *LoginLimiter) entry(entries map[string]*loginAttempts, key string, now time.Time) *loginAttempts {
+	attempts, ok := entries[key]
+	if !ok || l.stale(attempts, now) {
+		attempts = &loginAttempts{}
+		entries[key] = attempts
+	}
+	return attempts
+}
+
+func (l *LoginLimiter) stale(attempts *loginAttempts, now time.Time) bool {
+	return now.After(attempts.blockedUntil) && now.Sub(attempts.lastFailure) > l.lockout
+}
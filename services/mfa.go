@@ -0,0 +1,217 @@
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/YahyaCengiz/todo-v2/authz"
+	"github.com/YahyaCengiz/todo-v2/models"
+	"github.com/YahyaCengiz/todo-v2/store"
+)
+
+var (
+	ErrMFAEnabled     = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnabled  = errors.New("two-factor authentication is not enabled")
+	ErrMFANotEnrolled = errors.New("no two-factor enrolment in progress")
+	ErrInvalidMFACode = errors.New("invalid two-factor code")
+)
+
+const recoveryCodeCount = 10
+
+// TOTPEnrollment is what a user scans into their authenticator app.
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// EnrollTOTP starts setting up an authenticator app for the user. Logins
+// are unchanged until ConfirmTOTP; enrolling again replaces the pending
+// secret.
+func (s *UserService) EnrollTOTP(sub authz.Subject) (*TOTPEnrollment, error) {
+	if err := s.authz.Authorize(sub, authz.MFAEnroll); err != nil {
+		return nil, err
+	}
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.updateUser(sub.UserID, func(tx store.Repository, user *models.User) error {
+		if user.TOTPEnabled {
+			return ErrMFAEnabled
+		}
+		user.TOTPSecret = secret
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &TOTPEnrollment{Secret: secret, URI: totpURI(s.totpIssuer, user.Username, secret)}, nil
+}
+
+// ConfirmTOTP turns the second factor on once the user has shown a code
+// from the app, and returns the recovery codes. They are only stored
+// hashed, so this is the only time they can be shown.
+func (s *UserService) ConfirmTOTP(sub authz.Subject, code string) ([]string, error) {
+	if err := s.authz.Authorize(sub, authz.MFAEnroll); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.updateUser(sub.UserID, func(tx store.Repository, user *models.User) error {
+		if user.TOTPEnabled {
+			return ErrMFAEnabled
+		}
+		if user.TOTPSecret == "" {
+			return ErrMFANotEnrolled
+		}
+		step, ok := matchTOTP(user.TOTPSecret, strings.TrimSpace(code), user.TOTPLastStep, time.Now())
+		if !ok {
+			return ErrInvalidMFACode
+		}
+		user.TOTPEnabled = true
+		user.TOTPLastStep = step
+		user.RecoveryCodes = hashes
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTOTP turns the second factor off. It takes a current code or a
+// recovery code, so a stolen access token is not enough.
+func (s *UserService) DisableTOTP(sub authz.Subject, code, ip string) error {
+	_, err := s.withSecondFactor(sub.UserID, code, ip, func(user *models.User) error {
+		clearSecondFactor(user)
+		return nil
+	})
+	return err
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes, for when
+// they run low or may have been seen by someone else.
+func (s *UserService) RegenerateRecoveryCodes(sub authz.Subject, code, ip string) ([]string, error) {
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.withSecondFactor(sub.UserID, code, ip, func(user *models.User) error {
+		user.RecoveryCodes = hashes
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// LoginSecondFactor finishes a login that Login answered with a challenge.
+// code is either a current TOTP code or an unused recovery code.
+func (s *UserService) LoginSecondFactor(userID int, code, ip string) (*models.User, error) {
+	user, err := s.withSecondFactor(userID, code, ip, func(user *models.User) error {
+		if !user.DeactivatedAt.IsZero() {
+			return ErrInvalidCredentials
+		}
+		return nil
+	})
+	if errors.Is(err, store.ErrUserNotFound) || errors.Is(err, ErrMFANotEnabled) {
+		return nil, ErrInvalidCredentials
+	}
+	return user, err
+}
+
+// ResetMFA turns off the second factor of a user who lost both their
+// authenticator and their recovery codes.
+func (s *UserService) ResetMFA(id int, sub authz.Subject) (*models.User, error) {
+	return s.manageUser(id, sub, func(tx store.Repository, user *models.User) error {
+		clearSecondFactor(user)
+		return nil
+	})
+}
+
+// useSecondFactor checks code against the user's TOTP secret and recovery
+// codes, and uses it up on the user so that it cannot be presented again.
+// The caller has to save the user.
+func useSecondFactor(user *models.User, code string, now time.Time) bool {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return false
+	}
+	if step, ok := matchTOTP(user.TOTPSecret, code, user.TOTPLastStep, now); ok {
+		user.TOTPLastStep = step
+		return true
+	}
+	i := slices.Index(user.RecoveryCodes, hashToken(normalizeRecoveryCode(code)))
+	if i < 0 {
+		return false
+	}
+	user.RecoveryCodes = slices.Delete(user.RecoveryCodes, i, i+1)
+	return true
+}
+
+// withSecondFactor applies change to the user if code is a valid second
+// factor for them. Wrong codes are throttled like failed logins of the
+// username.
+func (s *UserService) withSecondFactor(userID int, code, ip string, change func(user *models.User) error) (*models.User, error) {
+	user, err := s.store.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TOTPEnabled {
+		return nil, ErrMFANotEnabled
+	}
+	if err := s.limiter.Begin(user.Username, ip); err != nil {
+		return nil, err
+	}
+
+	user, err = s.updateUser(userID, func(tx store.Repository, user *models.User) error {
+		if !user.TOTPEnabled {
+			return ErrMFANotEnabled
+		}
+		if !useSecondFactor(user, code, time.Now()) {
+			return ErrInvalidMFACode
+		}
+		return change(user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.limiter.Succeed(user.Username, ip)
+	return user, nil
+}
+
+func clearSecondFactor(user *models.User) {
+	user.TOTPSecret = ""
+	user.TOTPEnabled = false
+	user.TOTPLastStep = 0
+	user.RecoveryCodes = nil
+}
+
+// newRecoveryCodes returns recoveryCodeCount codes of 80 random bits, in
+// groups of four characters for easier copying, along with their hashes.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(totpEncoding.EncodeToString(b))
+		codes[i] = raw[0:4] + "-" + raw[4:8] + "-" + raw[8:12] + "-" + raw[12:16]
+		hashes[i] = hashToken(raw)
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode accepts recovery codes with or without the dashes
+// and in any case.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/YahyaCengiz/todo-v2/authz"
+	"github.com/YahyaCengiz/todo-v2/models"
+	"github.com/YahyaCengiz/todo-v2/store"
+)
+
+const maxOrganizationNameLength = 64
+
+// OrganizationService manages the organizations users and lists belong
+// to. Seeing and creating them needs orgs:manage; everyone may look up
+// their own.
+type OrganizationService struct {
+	store store.Repository
+	authz *authz.Authorizer
+}
+
+func NewOrganizationService(store store.Repository, authorizer *authz.Authorizer) *OrganizationService {
+	return &OrganizationService{store: store, authz: authorizer}
+}
+
+// Organization returns the subject's own organization.
+func (s *OrganizationService) Organization(sub authz.Subject) (*models.Organization, error) {
+	return s.store.GetOrganization(sub.OrgID)
+}
+
+func (s *OrganizationService) ListOrganizations(sub authz.Subject) ([]models.Organization, error) {
+	if err := s.authz.Authorize(sub, authz.OrgsManage); err != nil {
+		return nil, err
+	}
+	return s.store.GetOrganizations()
+}
+
+// CreateOrganization adds an empty organization. Its first admin is then
+// created with UserService.CreateUser.
+func (s *OrganizationService) CreateOrganization(name string, sub authz.Subject) (*models.Organization, error) {
+	if err := s.authz.Authorize(sub, authz.OrgsManage); err != nil {
+		return nil, err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxOrganizationNameLength {
+		return nil, fmt.Errorf("%w: name must be 1 to %d characters", ErrInvalidInput, maxOrganizationNameLength)
+	}
+
+	org := &models.Organization{Name: name, CreatedAt: time.Now()}
+	if err := s.store.CreateOrganization(org); err != nil {
+		return nil, err
+	}
+	return org, nil
+}
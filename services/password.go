@@ -0,0 +1,45 @@
+package services
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// passwordCost is the bcrypt work factor for new hashes. Stored hashes with a
+// lower cost are upgraded the next time their owner logs in.
+const passwordCost = 12
+
+// dummyHash is checked against when the username does not exist, so that a
+// failed login takes about as long either way.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), passwordCost)
+
+// hashPassword returns the bcrypt hash to store for password.
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// checkPassword reports whether password matches the stored value and
+// whether the stored value should be replaced with a fresh hash. Values
+// that are not bcrypt hashes are plaintext passwords from before hashing
+// was introduced.
+func checkPassword(stored, password string) (ok, rehash bool) {
+	if !isBcryptHash(stored) {
+		ok = subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+		return ok, ok
+	}
+	if bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) != nil {
+		return false, false
+	}
+	cost, err := bcrypt.Cost([]byte(stored))
+	return true, err != nil || cost < passwordCost
+}
+
+func isBcryptHash(s string) bool {
+	return strings.HasPrefix(s, "$2a$") || strings.HasPrefix(s, "$2b$") || strings.HasPrefix(s, "$2y$")
+}
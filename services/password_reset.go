@@ -0,0 +1,172 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/YahyaCengiz/todo-v2/mail"
+	"github.com/YahyaCengiz/todo-v2/middleware"
+	"github.com/YahyaCengiz/todo-v2/models"
+	"github.com/YahyaCengiz/todo-v2/store"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+
+const resetAudience = "password-reset"
+
+// resetLimiterPrefix keeps the email addresses throttled by RequestReset
+// apart from usernames in the login limiter.
+const resetLimiterPrefix = "reset:"
+
+// resetClaims are carried by password reset tokens. PasswordVersion is the
+// user's password version the token was issued for, so the token stops
+// working once the password changes, including through the token itself.
+// That makes it single-use without storing it. Tokens are only signed, so
+// they must not carry anything derived from the password.
+type resetClaims struct {
+	UserID          int `json:"user_id"`
+	PasswordVersion int `json:"pwv"`
+	jwt.RegisteredClaims
+}
+
+// PasswordResetService lets users who forgot their password set a new one
+// through a link sent to their email address.
+type PasswordResetService struct {
+	store   store.Repository
+	keys    *middleware.KeySet
+	mailer  mail.Mailer
+	limiter *LoginLimiter
+	ttl     time.Duration
+	// resetURL is the page the link in the email points to; the token is
+	// added as the "token" query parameter. Empty sends only the token.
+	resetURL string
+}
+
+func NewPasswordResetService(store store.Repository, keys *middleware.KeySet, mailer mail.Mailer, limiter *LoginLimiter, ttl time.Duration, resetURL string) *PasswordResetService {
+	return &PasswordResetService{
+		store:    store,
+		keys:     keys,
+		mailer:   mailer,
+		limiter:  limiter,
+		ttl:      ttl,
+		resetURL: resetURL,
+	}
+}
+
+// RequestReset mails a reset token to the account with the email address.
+// Unknown addresses, deactivated accounts and accounts that log in through
+// SSO, which keep no password here, are ignored without an error, and the
+// mail goes out in the background, so callers cannot tell whether
+// an account exists.
+//
+// Every request counts against the address and the client in the login
+// limiter, known address or not, so nobody can flood a mailbox.
+func (s *PasswordResetService) RequestReset(email, ip string) error {
+	email = normalizeEmail(email)
+	if err := validateEmail(email); err != nil {
+		return err
+	}
+	if err := s.limiter.Begin(resetLimiterPrefix+email, ip); err != nil {
+		return err
+	}
+	user, err := s.store.GetUserByEmail(email)
+	if errors.Is(err, store.ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !user.DeactivatedAt.IsZero() || user.SSOSubject != "" {
+		return nil
+	}
+
+	token, err := s.issue(user)
+	if err != nil {
+		return err
+	}
+	msg := s.message(user, token)
+	go func() {
+		if err := s.mailer.Send(msg); err != nil {
+			log.Printf("password reset mail for user %q failed: %v", user.Username, err)
+		}
+	}()
+	return nil
+}
+
+// ResetPassword sets a new password for the holder of a reset token, logs
+// the user out everywhere and lifts any login lockout.
+func (s *PasswordResetService) ResetPassword(token, password string) error {
+	claims := &resetClaims{}
+	if err := s.keys.Parse(token, claims); err != nil || !slices.Contains(claims.Audience, resetAudience) {
+		return ErrInvalidResetToken
+	}
+	if err := validatePassword(password); err != nil {
+		return err
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	var username string
+	err = s.store.Tx(func(tx store.Repository) error {
+		user, err := tx.GetUserByID(claims.UserID)
+		if errors.Is(err, store.ErrUserNotFound) {
+			return ErrInvalidResetToken
+		}
+		if err != nil {
+			return err
+		}
+		if !user.DeactivatedAt.IsZero() || user.SSOSubject != "" || user.PasswordVersion != claims.PasswordVersion {
+			return ErrInvalidResetToken
+		}
+		user.Password = hash
+		user.PasswordVersion++
+		username = user.Username
+		if err := tx.UpdateUser(*user); err != nil {
+			return err
+		}
+		return revokeUserSessions(tx, user.ID, time.Now())
+	})
+	if err != nil {
+		return err
+	}
+	s.limiter.Unlock(username)
+	return nil
+}
+
+func (s *PasswordResetService) issue(user *models.User) (string, error) {
+	now := time.Now()
+	return s.keys.Sign(&resetClaims{
+		UserID:          user.ID,
+		PasswordVersion: user.PasswordVersion,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{resetAudience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	})
+}
+
+func (s *PasswordResetService) message(user *models.User, token string) mail.Message {
+	instructions := fmt.Sprintf("use this token with POST /api/password/reset:\n\n%s\n", token)
+	if link, err := url.Parse(s.resetURL); err == nil && s.resetURL != "" {
+		query := link.Query()
+		query.Set("token", token)
+		link.RawQuery = query.Encode()
+		instructions = fmt.Sprintf("open this link:\n\n%s\n", link)
+	}
+	return mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Someone asked to reset the password of the account %q.\n\n"+
+			"To choose a new password, %s\n"+
+			"This expires in %s. If you did not ask for a new password, you can ignore this email.\n",
+			user.Username, instructions, s.ttl),
+	}
+}
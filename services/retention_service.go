@@ -0,0 +1,50 @@
+package services
+
+import (
+	"log"
+	"time"
+
+	"github.com/YahyaCengiz/todo-v2/store"
+)
+
+// RetentionService permanently removes soft-deleted lists and items once
+// they have been deleted for longer than the retention window.
+type RetentionService struct {
+	store  store.Repository
+	window time.Duration
+}
+
+func NewRetentionService(store store.Repository, window time.Duration) *RetentionService {
+	return &RetentionService{store: store, window: window}
+}
+
+// Run purges expired records every interval until the process exits. A
+// zero window or interval disables the background purge.
+func (s *RetentionService) Run(interval time.Duration) {
+	if s.window <= 0 || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		result, err := s.Purge(s.window)
+		if err != nil {
+			log.Printf("scheduled purge failed: %v", err)
+			continue
+		}
+		if len(result.ListIDs) > 0 || len(result.Items) > 0 {
+			log.Printf("purged %d todo lists and %d todo items deleted more than %s ago",
+				len(result.ListIDs), len(result.Items), s.window)
+		}
+	}
+}
+
+// Window is the configured retention window; zero keeps records forever.
+func (s *RetentionService) Window() time.Duration {
+	return s.window
+}
+
+// Purge removes everything that was soft-deleted more than olderThan ago.
+func (s *RetentionService) Purge(olderThan time.Duration) (*store.PurgeResult, error) {
+	return s.store.PurgeDeleted(time.Now().Add(-olderThan))
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/YahyaCengiz/todo-v2/models"
+	"github.com/YahyaCengiz/todo-v2/store"
+)
+
+// sessionSeenInterval limits how often a session's LastSeenAt is written,
+// so that busy clients do not cause a store write on every request.
+const sessionSeenInterval = time.Minute
+
+// SessionInfo describes the device a login comes from. Device is a label
+// the client may choose, such as "Work laptop".
+type SessionInfo struct {
+	Device    string
+	IP        string
+	UserAgent string
+}
+
+// CheckSession implements middleware.SessionChecker. A session whose
+// tokens have all expired and been cleaned up counts as revoked.
+func (s *TokenService) CheckSession(sessionID string) (bool, error) {
+	tokens, err := s.store.GetRefreshTokensByFamily(sessionID)
+	if err != nil {
+		return false, err
+	}
+	if familyRevoked(tokens) {
+		return false, nil
+	}
+	if err := s.markSeen(sessionID, time.Now()); err != nil {
+		log.Printf("failed to update session %s: %v", sessionID, err)
+	}
+	return true, nil
+}
+
+// ListSessions returns the user's sessions that are still valid, the most
+// recently used first.
+func (s *TokenService) ListSessions(userID int) ([]models.Session, error) {
+	sessions, err := s.store.GetSessionsByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	active := make([]models.Session, 0, len(sessions))
+	for _, session := range sessions {
+		if now.After(session.ExpiresAt) {
+			continue
+		}
+		tokens, err := s.store.GetRefreshTokensByFamily(session.ID)
+		if err != nil {
+			return nil, err
+		}
+		if !familyRevoked(tokens) {
+			active = append(active, session)
+		}
+	}
+	slices.SortFunc(active, func(a, b models.Session) int { return b.LastSeenAt.Compare(a.LastSeenAt) })
+	return active, nil
+}
+
+// RevokeUserSession logs out one of the user's sessions. Sessions of other
+// users and ones that already ended are reported as not found.
+func (s *TokenService) RevokeUserSession(userID int, sessionID string) error {
+	return s.store.Tx(func(tx store.Repository) error {
+		session, err := tx.GetSession(sessionID)
+		if err != nil {
+			return err
+		}
+		if session.UserID != userID {
+			return store.ErrSessionNotFound
+		}
+		tokens, err := tx.GetRefreshTokensByFamily(sessionID)
+		if err != nil {
+			return err
+		}
+		if familyRevoked(tokens) {
+			return store.ErrSessionNotFound
+		}
+		return revokeFamily(tx, sessionID, time.Now())
+	})
+}
+
+// markSeen moves the session's LastSeenAt to now, unless it was updated
+// less than sessionSeenInterval ago. Sessions from before session records
+// were kept have none and are skipped.
+func (s *TokenService) markSeen(sessionID string, now time.Time) error {
+	session, err := s.store.GetSession(sessionID)
+	if errors.Is(err, store.ErrSessionNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if now.Sub(session.LastSeenAt) < sessionSeenInterval {
+		return nil
+	}
+	return s.store.Tx(func(tx store.Repository) error {
+		return extendSession(tx, sessionID, now, session.ExpiresAt)
+	})
+}
+
+// extendSession records that the session was used at seen and now expires
+// at expiresAt.
+func extendSession(tx store.Repository, sessionID string, seen, expiresAt time.Time) error {
+	session, err := tx.GetSession(sessionID)
+	if errors.Is(err, store.ErrSessionNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	session.LastSeenAt = seen
+	session.ExpiresAt = expiresAt
+	return tx.UpdateSession(session)
+}
+
+// familyRevoked tells whether a refresh token family no longer grants
+// access: one of its tokens was revoked, or none are left.
+func familyRevoked(tokens []models.RefreshToken) bool {
+	if len(tokens) == 0 {
+		return true
+	}
+	for _, token := range tokens {
+		if !token.RevokedAt.IsZero() {
+			return true
+		}
+	}
+	return false
+}
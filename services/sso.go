@@ -0,0 +1,293 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/YahyaCengiz/todo-v2/authz"
+	"github.com/YahyaCengiz/todo-v2/middleware"
+	"github.com/YahyaCengiz/todo-v2/models"
+	"github.com/YahyaCengiz/todo-v2/oidc"
+	"github.com/YahyaCengiz/todo-v2/store"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrSSONotConfigured = errors.New("single sign-on is not configured")
+	ErrInvalidSSOState  = errors.New("invalid or expired single sign-on state")
+	// ErrSSODenied means the provider vouched for the user, but the
+	// user may not log in here.
+	ErrSSODenied = errors.New("single sign-on login not allowed")
+)
+
+// The state of a login in progress travels in a cookie, signed like our
+// other tokens so the server keeps nothing. Its audience keeps it from
+// passing as any other kind of token.
+const (
+	ssoStateAudience = "oidc-state"
+	ssoStateTTL      = 10 * time.Minute
+)
+
+type ssoStateClaims struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	jwt.RegisteredClaims
+}
+
+// usernameUnsafe matches what an SSO username may not contain here.
+var usernameUnsafe = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// RoleMapping gives Role to users whose role claim contains Value.
+type RoleMapping struct {
+	Value string
+	Role  string
+}
+
+// ParseRoleMappings reads comma-separated "value=role" pairs, such as
+// "todo-admins=admin,staff=user". The first pair that matches a user wins.
+func ParseRoleMappings(s string) ([]RoleMapping, error) {
+	var mappings []RoleMapping
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		value, role, ok := strings.Cut(pair, "=")
+		value, role = strings.TrimSpace(value), strings.TrimSpace(role)
+		if !ok || value == "" || role == "" {
+			return nil, fmt.Errorf("invalid role mapping %q, expected value=role", pair)
+		}
+		mappings = append(mappings, RoleMapping{Value: value, Role: role})
+	}
+	return mappings, nil
+}
+
+// SSOConfig says who may log in through the identity provider and with
+// which role.
+//
+// RoleClaim names the ID token claim that RoleMappings are matched
+// against. Users no mapping matches get DefaultRole, or are refused if it
+// is empty. With mappings, the role is updated on every login, so the
+// provider stays in charge of it; without, only new accounts get
+// DefaultRole. Provision creates accounts on the first login; otherwise
+// only already linked accounts can log in.
+type SSOConfig struct {
+	RoleClaim    string
+	RoleMappings []RoleMapping
+	DefaultRole  string
+	Provision    bool
+}
+
+// SSOService logs users in through an OpenID Connect provider and keeps
+// their accounts in line with it.
+type SSOService struct {
+	store    store.Repository
+	keys     *middleware.KeySet
+	provider *oidc.Provider
+	cfg      SSOConfig
+	secure   bool
+}
+
+// NewSSOService checks that every role in cfg exists. redirectURL is the
+// callback registered with the provider.
+func NewSSOService(store store.Repository, authorizer *authz.Authorizer, keys *middleware.KeySet, provider *oidc.Provider, redirectURL string, cfg SSOConfig) (*SSOService, error) {
+	for _, mapping := range cfg.RoleMappings {
+		if !authorizer.HasRole(mapping.Role) {
+			return nil, fmt.Errorf("role mapping %q: unknown role %q", mapping.Value, mapping.Role)
+		}
+	}
+	if cfg.DefaultRole != "" && !authorizer.HasRole(cfg.DefaultRole) {
+		return nil, fmt.Errorf("unknown default SSO role %q", cfg.DefaultRole)
+	}
+	return &SSOService{
+		store:    store,
+		keys:     keys,
+		provider: provider,
+		cfg:      cfg,
+		secure:   strings.HasPrefix(redirectURL, "https://"),
+	}, nil
+}
+
+// SSOLogin starts a login: the browser is sent to URL and keeps State, to
+// be handed back with the callback. Secure tells whether the callback is
+// served over HTTPS, so State need not be sent over anything else.
+type SSOLogin struct {
+	URL       string
+	State     string
+	ExpiresIn int
+	Secure    bool
+}
+
+// Begin starts a login with a fresh state, nonce and PKCE verifier.
+func (s *SSOService) Begin(ctx context.Context) (*SSOLogin, error) {
+	state, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := oidc.NewVerifier()
+	if err != nil {
+		return nil, err
+	}
+
+	authURL, err := s.provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	signed, err := s.keys.Sign(&ssoStateClaims{
+		State:    state,
+		Nonce:    nonce,
+		Verifier: verifier,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{ssoStateAudience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(ssoStateTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &SSOLogin{
+		URL:       authURL,
+		State:     signed,
+		ExpiresIn: int(ssoStateTTL / time.Second),
+		Secure:    s.secure,
+	}, nil
+}
+
+// Complete finishes a login with the code and state the provider sent
+// back and the state kept from Begin. It returns the account the provider
+// vouched for, creating it on the first login if provisioning is on.
+func (s *SSOService) Complete(ctx context.Context, code, state, saved string) (*models.User, error) {
+	claims := &ssoStateClaims{}
+	if err := s.keys.Parse(saved, claims); err != nil || !slices.Contains(claims.Audience, ssoStateAudience) {
+		return nil, ErrInvalidSSOState
+	}
+	if code == "" || subtle.ConstantTimeCompare([]byte(state), []byte(claims.State)) != 1 {
+		return nil, ErrInvalidSSOState
+	}
+
+	token, err := s.provider.Exchange(ctx, code, claims.Verifier, claims.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	role := s.mapRole(token)
+
+	var user *models.User
+	err = s.store.Tx(func(tx store.Repository) error {
+		var err error
+		user, err = tx.GetUserBySSOSubject(token.Issuer, token.Subject)
+		if errors.Is(err, store.ErrUserNotFound) {
+			user, err = s.provision(tx, token, role)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if !user.DeactivatedAt.IsZero() {
+			return fmt.Errorf("%w: the account is deactivated", ErrSSODenied)
+		}
+		if len(s.cfg.RoleMappings) == 0 || user.Role == role {
+			return nil
+		}
+		if role == "" {
+			return fmt.Errorf("%w: no role is mapped to the user's %s", ErrSSODenied, s.cfg.RoleClaim)
+		}
+		user.Role = role
+		return tx.UpdateUser(*user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// provision creates the account for a first SSO login. The username comes
+// from the token but never takes over an existing account: a taken name
+// gets a number appended instead.
+func (s *SSOService) provision(tx store.Repository, token *oidc.IDToken, role string) (*models.User, error) {
+	if !s.cfg.Provision {
+		return nil, fmt.Errorf("%w: no account is linked to this identity", ErrSSODenied)
+	}
+	if role == "" {
+		return nil, fmt.Errorf("%w: no role is mapped to the user's %s", ErrSSODenied, s.cfg.RoleClaim)
+	}
+	username, err := uniqueUsername(tx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{
+		OrgID:      models.DefaultOrganizationID,
+		Username:   username,
+		Role:       role,
+		SSOIssuer:  token.Issuer,
+		SSOSubject: token.Subject,
+	}
+	// Only an address the provider checked is taken over, and only if
+	// nobody here uses it yet. It never resets a password, since the
+	// account has none.
+	if email := normalizeEmail(token.String("email")); token.Bool("email_verified") && validateEmail(email) == nil {
+		if _, err := tx.GetUserByEmail(email); errors.Is(err, store.ErrUserNotFound) {
+			user.Email = email
+		}
+	}
+	if err := tx.AddUser(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// mapRole returns the role for the token's user, or "" if they get none.
+func (s *SSOService) mapRole(token *oidc.IDToken) string {
+	values := token.Strings(s.cfg.RoleClaim)
+	for _, mapping := range s.cfg.RoleMappings {
+		if slices.Contains(values, mapping.Value) {
+			return mapping.Role
+		}
+	}
+	return s.cfg.DefaultRole
+}
+
+// uniqueUsername derives a free, valid username from the token's
+// preferred_username or email address.
+func uniqueUsername(tx store.Repository, token *oidc.IDToken) (string, error) {
+	base := token.String("preferred_username")
+	if base == "" {
+		base, _, _ = strings.Cut(token.String("email"), "@")
+	}
+	base = strings.TrimLeft(usernameUnsafe.ReplaceAllString(base, "-"), "_.-")
+	if len(base) > 28 {
+		base = base[:28]
+	}
+	if len(base) < 3 {
+		base = "sso-user"
+	}
+
+	for n := 1; n < 100; n++ {
+		username := base
+		if n > 1 {
+			username = fmt.Sprintf("%s-%d", base, n)
+		}
+		if validateUsername(username) != nil {
+			continue
+		}
+		if _, err := tx.GetUserByUsername(username); errors.Is(err, store.ErrUserNotFound) {
+			return username, nil
+		} else if err != nil {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("no free username for %q", base)
+}
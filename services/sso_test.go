@@ -0,0 +1,357 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/YahyaCengiz/todo-v2/authz"
+	"github.com/YahyaCengiz/todo-v2/middleware"
+	"github.com/YahyaCengiz/todo-v2/models"
+	"github.com/YahyaCengiz/todo-v2/oidc"
+	"github.com/YahyaCengiz/todo-v2/store"
+)
+
+const (
+	testClientID     = "todo-v2"
+	testClientSecret = "mock-secret"
+	testRedirectURL  = "http://todo.test/api/oidc/callback"
+)
+
+var testIdPUsers = []oidc.MockUser{
+	{Username: "alice", Email: "alice@example.com", Groups: []string{"todo-admins"}},
+	{Username: "bob", Email: "bob@example.com"},
+	{Username: "carol"},
+}
+
+// ssoTest runs SSOService against an oidc.MockProvider served over HTTP.
+type ssoTest struct {
+	store    *store.Store
+	keys     *middleware.KeySet
+	provider *oidc.Provider
+	idp      *httptest.Server
+}
+
+func newSSOTest(t *testing.T) *ssoTest {
+	t.Helper()
+	var mock *oidc.MockProvider
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mock.ServeHTTP(w, r)
+	}))
+	t.Cleanup(idp.Close)
+
+	var err error
+	mock, err = oidc.NewMockProvider(idp.URL, testClientID, testClientSecret, testIdPUsers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := middleware.LoadKeySet(middleware.KeyConfig{Secret: "test-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &ssoTest{
+		store: store.NewMemoryStore(),
+		keys:  keys,
+		provider: oidc.NewProvider(oidc.Config{
+			Issuer:       idp.URL,
+			ClientID:     testClientID,
+			ClientSecret: testClientSecret,
+			RedirectURL:  testRedirectURL,
+		}),
+		idp: idp,
+	}
+}
+
+// service returns an SSOService with cfg. Services made by one ssoTest
+// share the store and provider, like restarts with other flags would.
+func (st *ssoTest) service(t *testing.T, cfg SSOConfig) *SSOService {
+	t.Helper()
+	if cfg.RoleClaim == "" {
+		cfg.RoleClaim = "groups"
+	}
+	authorizer, err := authz.New(authz.DefaultRoles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSSOService(st.store, authorizer, st.keys, st.provider, testRedirectURL, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// authorize starts a login for the mock user username and follows the
+// browser to the provider. It returns the code and state the provider
+// redirected back with and the state Begin asked the browser to keep.
+func (st *ssoTest) authorize(t *testing.T, s *SSOService, username string) (code, state, saved string) {
+	t.Helper()
+	login, err := s.Begin(context.Background())
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	authURL, err := url.Parse(login.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := authURL.Query()
+	if query.Get("code_challenge") == "" || query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("authorization URL without a PKCE challenge: %s", login.URL)
+	}
+	if query.Get("nonce") == "" {
+		t.Fatalf("authorization URL without a nonce: %s", login.URL)
+	}
+	query.Set("login_hint", username)
+	authURL.RawQuery = query.Encode()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	callback, err := resp.Location()
+	if err != nil {
+		t.Fatalf("provider did not redirect: %s", resp.Status)
+	}
+	params := callback.Query()
+	if params.Get("error") != "" {
+		t.Fatalf("provider answered %s", params.Get("error"))
+	}
+	return params.Get("code"), params.Get("state"), login.State
+}
+
+func (st *ssoTest) login(t *testing.T, s *SSOService, username string) (*models.User, error) {
+	t.Helper()
+	code, state, saved := st.authorize(t, s, username)
+	return s.Complete(context.Background(), code, state, saved)
+}
+
+// resign returns saved with change applied to its claims, signed again.
+func (st *ssoTest) resign(t *testing.T, saved string, change func(*ssoStateClaims)) string {
+	t.Helper()
+	claims := &ssoStateClaims{}
+	if err := st.keys.Parse(saved, claims); err != nil {
+		t.Fatal(err)
+	}
+	change(claims)
+	signed, err := st.keys.Sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestSSOProvisionsAndMapsRoles(t *testing.T) {
+	st := newSSOTest(t)
+	s := st.service(t, SSOConfig{
+		RoleMappings: []RoleMapping{{Value: "todo-admins", Role: "admin"}},
+		DefaultRole:  "user",
+		Provision:    true,
+	})
+
+	alice, err := st.login(t, s, "alice")
+	if err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+	if alice.Username != "alice" || alice.Role != "admin" || alice.OrgID != models.DefaultOrganizationID {
+		t.Errorf("provisioned %+v, want alice as admin of the default organization", alice)
+	}
+	if alice.SSOIssuer != st.idp.URL || alice.SSOSubject != "mock-alice" || alice.Email != "alice@example.com" {
+		t.Errorf("provisioned alice linked to %q/%q with email %q", alice.SSOIssuer, alice.SSOSubject, alice.Email)
+	}
+	if alice.Password != "" {
+		t.Error("provisioned account has a password")
+	}
+
+	again, err := st.login(t, s, "alice")
+	if err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+	if again.ID != alice.ID {
+		t.Errorf("second login returned user %d, want %d", again.ID, alice.ID)
+	}
+
+	bob, err := st.login(t, s, "bob")
+	if err != nil {
+		t.Fatalf("bob: %v", err)
+	}
+	if bob.Role != "user" {
+		t.Errorf("bob got role %q, want the default role user", bob.Role)
+	}
+
+	// Mappings are applied on every login, so the provider can demote.
+	demoting := st.service(t, SSOConfig{
+		RoleMappings: []RoleMapping{{Value: "todo-admins", Role: "user"}},
+		DefaultRole:  "user",
+		Provision:    true,
+	})
+	demoted, err := st.login(t, demoting, "alice")
+	if err != nil {
+		t.Fatalf("login after remapping: %v", err)
+	}
+	stored, err := st.store.GetUserByID(alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if demoted.Role != "user" || stored.Role != "user" {
+		t.Errorf("alice is %q (stored %q) after remapping, want user", demoted.Role, stored.Role)
+	}
+}
+
+func TestSSOProvisioningOff(t *testing.T) {
+	st := newSSOTest(t)
+	provisioning := st.service(t, SSOConfig{DefaultRole: "user", Provision: true})
+	if _, err := st.login(t, provisioning, "alice"); err != nil {
+		t.Fatalf("provisioning alice: %v", err)
+	}
+
+	s := st.service(t, SSOConfig{DefaultRole: "user"})
+	if _, err := st.login(t, s, "alice"); err != nil {
+		t.Errorf("linked account refused without provisioning: %v", err)
+	}
+	if _, err := st.login(t, s, "carol"); !errors.Is(err, ErrSSODenied) {
+		t.Errorf("unlinked account: got %v, want ErrSSODenied", err)
+	}
+	if _, err := st.store.GetUserByUsername("carol"); !errors.Is(err, store.ErrUserNotFound) {
+		t.Errorf("carol was created without provisioning: %v", err)
+	}
+}
+
+func TestSSORefusesUsersWithoutRole(t *testing.T) {
+	st := newSSOTest(t)
+	s := st.service(t, SSOConfig{
+		RoleMappings: []RoleMapping{{Value: "todo-admins", Role: "admin"}},
+		Provision:    true,
+	})
+
+	if _, err := st.login(t, s, "bob"); !errors.Is(err, ErrSSODenied) {
+		t.Errorf("bob without a mapped role: got %v, want ErrSSODenied", err)
+	}
+	if _, err := st.login(t, s, "alice"); err != nil {
+		t.Errorf("alice with a mapped role: %v", err)
+	}
+}
+
+func TestSSORefusesDeactivatedUsers(t *testing.T) {
+	st := newSSOTest(t)
+	s := st.service(t, SSOConfig{DefaultRole: "user", Provision: true})
+	bob, err := st.login(t, s, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bob.DeactivatedAt = time.Now()
+	if err := st.store.UpdateUser(*bob); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.login(t, s, "bob"); !errors.Is(err, ErrSSODenied) {
+		t.Errorf("deactivated bob: got %v, want ErrSSODenied", err)
+	}
+}
+
+func TestSSORejectsForgedLogins(t *testing.T) {
+	st := newSSOTest(t)
+	s := st.service(t, SSOConfig{DefaultRole: "user", Provision: true})
+	otherKeys, err := middleware.LoadKeySet(middleware.KeyConfig{Secret: "other-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		// forge turns a genuine callback into the one under test.
+		forge func(code, state, saved string) (string, string, string)
+		want  error
+	}{
+		{
+			name: "wrong state",
+			forge: func(code, state, saved string) (string, string, string) {
+				return code, "bogus", saved
+			},
+			want: ErrInvalidSSOState,
+		},
+		{
+			name: "missing code",
+			forge: func(code, state, saved string) (string, string, string) {
+				return "", state, saved
+			},
+			want: ErrInvalidSSOState,
+		},
+		{
+			name: "state signed by another key",
+			forge: func(code, state, saved string) (string, string, string) {
+				claims := &ssoStateClaims{}
+				if err := st.keys.Parse(saved, claims); err != nil {
+					t.Fatal(err)
+				}
+				forged, err := otherKeys.Sign(claims)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return code, state, forged
+			},
+			want: ErrInvalidSSOState,
+		},
+		{
+			name: "access token as state",
+			forge: func(code, state, saved string) (string, string, string) {
+				token, err := st.keys.GenerateToken(1, 1, "admin", "admin", "sid", time.Minute)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return code, state, token
+			},
+			want: ErrInvalidSSOState,
+		},
+		{
+			name: "wrong nonce",
+			forge: func(code, state, saved string) (string, string, string) {
+				return code, state, st.resign(t, saved, func(c *ssoStateClaims) { c.Nonce = "other-nonce" })
+			},
+			want: oidc.ErrInvalidIDToken,
+		},
+		{
+			name: "wrong PKCE verifier",
+			forge: func(code, state, saved string) (string, string, string) {
+				verifier, err := oidc.NewVerifier()
+				if err != nil {
+					t.Fatal(err)
+				}
+				return code, state, st.resign(t, saved, func(c *ssoStateClaims) { c.Verifier = verifier })
+			},
+			want: oidc.ErrCodeRejected,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, state, saved := tt.forge(st.authorize(t, s, "bob"))
+			user, err := s.Complete(context.Background(), code, state, saved)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("got %v, %v; want %v", user, err, tt.want)
+			}
+		})
+	}
+
+	if _, err := st.store.GetUserByUsername("bob"); !errors.Is(err, store.ErrUserNotFound) {
+		t.Errorf("a forged login provisioned bob: %v", err)
+	}
+}
+
+func TestSSOCodeWorksOnce(t *testing.T) {
+	st := newSSOTest(t)
+	s := st.service(t, SSOConfig{DefaultRole: "user", Provision: true})
+
+	code, state, saved := st.authorize(t, s, "bob")
+	if _, err := s.Complete(context.Background(), code, state, saved); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if _, err := s.Complete(context.Background(), code, state, saved); !errors.Is(err, oidc.ErrCodeRejected) {
+		t.Errorf("replayed code: got %v, want ErrCodeRejected", err)
+	}
+}
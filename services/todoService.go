@@ -1,29 +1,35 @@
 package services
 
 import (
-	"errors"
+	"slices"
 	"time"
 
+	"github.com/YahyaCengiz/todo-v2/authz"
 	"github.com/YahyaCengiz/todo-v2/models"
 	"github.com/YahyaCengiz/todo-v2/store"
 )
 
 type TodoService struct {
-	store *store.Store
+	store store.Repository
+	authz *authz.Authorizer
 }
 
-func NewTodoService(store *store.Store) *TodoService {
-	return &TodoService{store: store}
+func NewTodoService(store store.Repository, authorizer *authz.Authorizer) *TodoService {
+	return &TodoService{store: store, authz: authorizer}
 }
 
-func (s *TodoService) CreateTodoList(name string, userID int) (*models.TodoList, error) {
+func (s *TodoService) CreateTodoList(name string, sub authz.Subject) (*models.TodoList, error) {
+	if err := s.authz.Authorize(sub, authz.ListsWrite); err != nil {
+		return nil, err
+	}
 	todoList := &models.TodoList{
 		Name:                 name,
 		CreatedAt:           time.Now(),
 		UpdatedAt:           time.Now(),
 		CompletionPercentage: 0,
 		TodoItems:           []models.TodoItem{},
-		UserID:              userID,
+		UserID:              sub.UserID,
+		OrgID:               sub.OrgID,
 	}
 	
 	if err := s.store.CreateTodoList(todoList); err != nil {
@@ -32,20 +38,20 @@ func (s *TodoService) CreateTodoList(name string, userID int) (*models.TodoList,
 	return s.store.GetTodoList(todoList.ID)
 }
 
-func (s *TodoService) GetTodoList(id int, userID int, role string) (*models.TodoList, error) {
+func (s *TodoService) GetTodoList(id int, sub authz.Subject) (*models.TodoList, error) {
 	todoList, err := s.store.GetTodoList(id)
 	if err != nil {
 		return nil, err
 	}
 	if !todoList.DeletedAt.IsZero() {
-		return nil, errors.New("todo list not found")
+		return nil, store.ErrListNotFound
 	}
-	if role != "admin" && todoList.UserID != userID {
-		return nil, errors.New("forbidden")
+	if err := s.authorizeOnList(sub, authz.ListsRead, todoList, todoList.UserID); err != nil {
+		return nil, err
 	}
 	filteredItems := make([]models.TodoItem, 0)
 	for _, item := range todoList.TodoItems {
-		if item.DeletedAt.IsZero() && (role == "admin" || item.UserID == userID) {
+		if item.DeletedAt.IsZero() && s.canOnList(sub, authz.ListsRead, todoList, item.UserID) {
 			filteredItems = append(filteredItems, item)
 		}
 	}
@@ -53,18 +59,18 @@ func (s *TodoService) GetTodoList(id int, userID int, role string) (*models.Todo
 	return todoList, nil
 }
 
-func (s *TodoService) GetAllTodoLists(userID int, role string) ([]*models.TodoList, error) {
-	lists, err := s.store.GetAllTodoLists()
+func (s *TodoService) GetAllTodoLists(sub authz.Subject) ([]*models.TodoList, error) {
+	lists, err := s.readableLists(sub)
 	if err != nil {
 		return nil, err
 	}
 	filteredLists := make([]*models.TodoList, 0)
 	for _, list := range lists {
-		if list.DeletedAt.IsZero() && (role == "admin" || list.UserID == userID) {
-			// Filter out deleted items and items not owned by user (unless admin)
+		if list.DeletedAt.IsZero() {
+			// Filter out deleted items and items the subject may not read
 			filteredItems := make([]models.TodoItem, 0)
 			for _, item := range list.TodoItems {
-				if item.DeletedAt.IsZero() && (role == "admin" || item.UserID == userID) {
+				if item.DeletedAt.IsZero() && s.canOnList(sub, authz.ListsRead, list, item.UserID) {
 					filteredItems = append(filteredItems, item)
 				}
 			}
@@ -75,120 +81,219 @@ func (s *TodoService) GetAllTodoLists(userID int, role string) ([]*models.TodoLi
 	return filteredLists, nil
 }
 
-func (s *TodoService) UpdateTodoList(id int, name string, userID int, role string) (*models.TodoList, error) {
-	todoList, err := s.store.GetTodoList(id)
-	if err != nil {
-		return nil, err
-	}
-	if role != "admin" && todoList.UserID != userID {
-		return nil, errors.New("forbidden")
+// readableLists returns everyone's lists in the subject's organization, or
+// its own and the ones shared with it, depending on what it may read.
+func (s *TodoService) readableLists(sub authz.Subject) ([]*models.TodoList, error) {
+	if s.authz.Can(sub, authz.ListsReadAny) {
+		return s.store.GetTodoListsByOrg(sub.OrgID)
 	}
-	todoList.Name = name
-	todoList.UpdatedAt = time.Now()
-	
-	if err := s.store.UpdateTodoList(todoList); err != nil {
+	if err := s.authz.Authorize(sub, authz.ListsRead); err != nil {
 		return nil, err
 	}
-	return todoList, nil
-}
-
-func (s *TodoService) DeleteTodoList(id int, userID int, role string) error {
-	todoList, err := s.store.GetTodoList(id)
+	owned, err := s.store.GetTodoListsByUser(sub.UserID)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if role != "admin" && todoList.UserID != userID {
-		return errors.New("forbidden")
+	shared, err := s.store.GetTodoListsByMember(sub.UserID)
+	if err != nil {
+		return nil, err
 	}
-	todoList.DeletedAt = time.Now()
-	return s.store.UpdateTodoList(todoList)
+	// Owners and members are always in the list's organization; the filter
+	// only makes sure a slip elsewhere cannot widen what is returned.
+	lists := append(owned, shared...)
+	lists = slices.DeleteFunc(lists, func(l *models.TodoList) bool { return l.OrgID != sub.OrgID })
+	slices.SortFunc(lists, func(a, b *models.TodoList) int { return a.ID - b.ID })
+	return lists, nil
 }
 
-func (s *TodoService) CreateTodoItem(listID int, content string, userID int, role string) (*models.TodoItem, error) {
-	todoList, err := s.store.GetTodoList(listID)
+// UpdateTodoList renames a list. The whole list is written back, so it is
+// read and written in one transaction to keep concurrent item and member
+// changes.
+func (s *TodoService) UpdateTodoList(id int, name string, sub authz.Subject) (*models.TodoList, error) {
+	var todoList *models.TodoList
+	err := s.store.Tx(func(tx store.Repository) error {
+		var err error
+		todoList, err = tx.GetTodoList(id)
+		if err != nil {
+			return err
+		}
+		if !todoList.DeletedAt.IsZero() {
+			return store.ErrListNotFound
+		}
+		if err := s.authorizeOnList(sub, authz.ListsWrite, todoList, todoList.UserID); err != nil {
+			return err
+		}
+		todoList.Name = name
+		todoList.UpdatedAt = time.Now()
+		return tx.UpdateTodoList(todoList)
+	})
 	if err != nil {
 		return nil, err
 	}
-	if role != "admin" && todoList.UserID != userID {
-		return nil, errors.New("forbidden")
-	}
+	return todoList, nil
+}
+
+// DeleteTodoList soft-deletes the list together with its remaining items.
+// They share one timestamp, which is how RestoreTodoList tells the items
+// deleted with the list apart from ones deleted earlier.
+func (s *TodoService) DeleteTodoList(id int, sub authz.Subject) error {
+	return s.store.Tx(func(tx store.Repository) error {
+		todoList, err := tx.GetTodoList(id)
+		if err != nil {
+			return err
+		}
+		if err := s.authorizeOnList(sub, authz.ListsWrite, todoList, todoList.UserID); err != nil {
+			return err
+		}
+		if !todoList.DeletedAt.IsZero() {
+			return store.ErrListNotFound
+		}
+
+		now := time.Now()
+		todoList.DeletedAt = now
+		if err := tx.UpdateTodoList(todoList); err != nil {
+			return err
+		}
+		for _, item := range todoList.TodoItems {
+			if item.DeletedAt.IsZero() {
+				item.DeletedAt = now
+				if err := tx.UpdateTodoItem(id, &item); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (s *TodoService) CreateTodoItem(listID int, content string, sub authz.Subject) (*models.TodoItem, error) {
 	todoItem := &models.TodoItem{
 		TodoListID:  listID,
 		Content:     content,
 		IsCompleted: false,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
-		UserID:      userID,
+		UserID:      sub.UserID,
 	}
 
-	if err := s.store.CreateTodoItem(todoItem); err != nil {
+	err := s.store.Tx(func(tx store.Repository) error {
+		todoList, err := tx.GetTodoList(listID)
+		if err != nil {
+			return err
+		}
+		if !todoList.DeletedAt.IsZero() {
+			return store.ErrListNotFound
+		}
+		if err := s.authorizeOnList(sub, authz.ItemsWrite, todoList, todoList.UserID); err != nil {
+			return err
+		}
+		if err := tx.CreateTodoItem(todoItem); err != nil {
+			return err
+		}
+		return updateCompletionPercentage(tx, listID)
+	})
+	if err != nil {
 		return nil, err
 	}
+	return todoItem, nil
+}
 
-
-	todoList, err = s.store.GetTodoList(listID)
+// UpdateTodoItem needs items:write to change the content and items:complete
+// to change the completion state.
+func (s *TodoService) UpdateTodoItem(listID, itemID int, content string, isCompleted bool, sub authz.Subject) (*models.TodoItem, error) {
+	var todoItem *models.TodoItem
+	err := s.store.Tx(func(tx store.Repository) error {
+		todoList, err := tx.GetTodoList(listID)
+		if err != nil {
+			return err
+		}
+		todoItem, err = tx.GetTodoItem(listID, itemID)
+		if err != nil {
+			return err
+		}
+		if err := checkNotDeleted(todoList, todoItem); err != nil {
+			return err
+		}
+		if err := s.authorizeItemUpdate(sub, todoList, todoItem, content, isCompleted); err != nil {
+			return err
+		}
+		todoItem.Content = content
+		todoItem.IsCompleted = isCompleted
+		todoItem.UpdatedAt = time.Now()
+		if err := tx.UpdateTodoItem(listID, todoItem); err != nil {
+			return err
+		}
+		return updateCompletionPercentage(tx, listID)
+	})
 	if err != nil {
 		return nil, err
 	}
+	return todoItem, nil
+}
 
-	var createdItem *models.TodoItem
-	maxID := -1
-	for i := range todoList.TodoItems {
-		item := &todoList.TodoItems[i]
-		if item.UserID == userID && item.ID > maxID {
-			maxID = item.ID
-			createdItem = item
+func (s *TodoService) authorizeItemUpdate(sub authz.Subject, todoList *models.TodoList, todoItem *models.TodoItem, content string, isCompleted bool) error {
+	contentChanged := content != todoItem.Content
+	completionChanged := isCompleted != todoItem.IsCompleted
+	if contentChanged {
+		if err := s.authorizeOnList(sub, authz.ItemsWrite, todoList, todoItem.UserID); err != nil {
+			return err
+		}
+	}
+	if completionChanged {
+		if err := s.authorizeOnList(sub, authz.ItemsComplete, todoList, todoItem.UserID); err != nil {
+			return err
 		}
 	}
-	if createdItem == nil {
-		return nil, errors.New("failed to add todo item")
+	if !contentChanged && !completionChanged &&
+		!s.canOnList(sub, authz.ItemsWrite, todoList, todoItem.UserID) && !s.canOnList(sub, authz.ItemsComplete, todoList, todoItem.UserID) {
+		return authz.ErrForbidden
 	}
-	return createdItem, nil
+	return nil
 }
 
-func (s *TodoService) UpdateTodoItem(listID, itemID int, content string, isCompleted bool, userID int, role string) (*models.TodoItem, error) {
-	todoList, err := s.store.GetTodoList(listID)
-	if err != nil {
-		return nil, err
-	}
-	todoItem, err := s.store.GetTodoItem(listID, itemID)
-	if err != nil {
-		return nil, err
-	}
-	if role != "admin" && todoItem.UserID != userID {
-		return nil, errors.New("forbidden")
-	}
-	todoItem.Content = content
-	todoItem.IsCompleted = isCompleted
-	todoItem.UpdatedAt = time.Now()
-	if err := s.store.UpdateTodoItem(listID, todoItem); err != nil {
-		return nil, err
-	}
-	s.updateCompletionPercentage(todoList)
-	return todoItem, nil
+func (s *TodoService) DeleteTodoItem(listID, itemID int, sub authz.Subject) error {
+	return s.store.Tx(func(tx store.Repository) error {
+		todoList, err := tx.GetTodoList(listID)
+		if err != nil {
+			return err
+		}
+		todoItem, err := tx.GetTodoItem(listID, itemID)
+		if err != nil {
+			return err
+		}
+		if err := checkNotDeleted(todoList, todoItem); err != nil {
+			return err
+		}
+		if err := s.authorizeOnList(sub, authz.ItemsWrite, todoList, todoItem.UserID); err != nil {
+			return err
+		}
+		todoItem.DeletedAt = time.Now()
+		if err := tx.UpdateTodoItem(listID, todoItem); err != nil {
+			return err
+		}
+		return updateCompletionPercentage(tx, listID)
+	})
 }
 
-func (s *TodoService) DeleteTodoItem(listID, itemID int, userID int, role string) error {
-	todoList, err := s.store.GetTodoList(listID)
-	if err != nil {
-		return err
-	}
-	todoItem, err := s.store.GetTodoItem(listID, itemID)
-	if err != nil {
-		return err
-	}
-	if role != "admin" && todoItem.UserID != userID {
-		return errors.New("forbidden")
+// checkNotDeleted hides soft-deleted lists and items from changes; they
+// are only reachable through the trash until they are restored.
+func checkNotDeleted(todoList *models.TodoList, todoItem *models.TodoItem) error {
+	if !todoList.DeletedAt.IsZero() {
+		return store.ErrListNotFound
 	}
-	todoItem.DeletedAt = time.Now()
-	if err := s.store.UpdateTodoItem(listID, todoItem); err != nil {
-		return err
+	if !todoItem.DeletedAt.IsZero() {
+		return store.ErrItemNotFound
 	}
-	s.updateCompletionPercentage(todoList)
 	return nil
 }
 
-func (s *TodoService) updateCompletionPercentage(todoList *models.TodoList) {
+// updateCompletionPercentage recalculates the percentage from the stored
+// items. It runs in the same transaction as the item change that caused it.
+func updateCompletionPercentage(tx store.Repository, listID int) error {
+	todoList, err := tx.GetTodoList(listID)
+	if err != nil {
+		return err
+	}
 	total := 0
 	completed := 0
 	for _, item := range todoList.TodoItems {
@@ -205,5 +310,5 @@ func (s *TodoService) updateCompletionPercentage(todoList *models.TodoList) {
 		todoList.CompletionPercentage = (completed * 100) / total
 	}
 	todoList.UpdatedAt = time.Now()
-	s.store.UpdateTodoList(todoList)
-} 
\ No newline at end of file
+	return tx.UpdateTodoList(todoList)
+}
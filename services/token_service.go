@@ -0,0 +1,296 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/YahyaCengiz/todo-v2/middleware"
+	"github.com/YahyaCengiz/todo-v2/models"
+	"github.com/YahyaCengiz/todo-v2/store"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused means an already rotated refresh token was
+	// presented again. Either the client or an attacker holds a stolen
+	// copy, so the whole session is revoked.
+	ErrRefreshTokenReused = errors.New("refresh token was already used, session revoked")
+	ErrInvalidChallenge   = errors.New("invalid or expired challenge token")
+)
+
+// A challenge token stands for a correct password while the second factor
+// is still missing. Its audience keeps it from passing as an access token,
+// here or anywhere verifying with the published keys, and keeps access
+// tokens from passing as challenges.
+const (
+	challengeAudience = "mfa-challenge"
+	challengeTTL      = 5 * time.Minute
+)
+
+type challengeClaims struct {
+	UserID int `json:"user_id"`
+	jwt.RegisteredClaims
+}
+
+// Challenge is what Login answers with when a second factor is required.
+type Challenge struct {
+	Token     string `json:"challenge_token"`
+	ExpiresIn int    `json:"expires_in"`
+}
+
+// TokenPair is what a client receives on login and on every refresh.
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// TokenService issues short-lived access tokens together with rotating
+// refresh tokens. Each login starts a refresh token family; the family ID
+// is the session ID in the access tokens, and revoking the family logs the
+// session out everywhere at once.
+type TokenService struct {
+	store      store.Repository
+	keys       *middleware.KeySet
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+func NewTokenService(store store.Repository, keys *middleware.KeySet, accessTTL, refreshTTL time.Duration) *TokenService {
+	return &TokenService{
+		store:      store,
+		keys:       keys,
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+	}
+}
+
+// Issue starts a new session for user on the device described by info.
+func (s *TokenService) Issue(user *models.User, info SessionInfo) (*TokenPair, error) {
+	familyID, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	var pair *TokenPair
+	err = s.store.Tx(func(tx store.Repository) error {
+		now := time.Now()
+		err := tx.CreateSession(&models.Session{
+			ID:         familyID,
+			UserID:     user.ID,
+			Device:     info.Device,
+			IP:         info.IP,
+			UserAgent:  info.UserAgent,
+			CreatedAt:  now,
+			LastSeenAt: now,
+			ExpiresAt:  now.Add(s.refreshTTL),
+		})
+		if err != nil {
+			return err
+		}
+		pair, err = s.issue(tx, user, familyID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// Refresh trades a refresh token for a new pair. The old refresh token
+// stops working; presenting it again revokes the session.
+func (s *TokenService) Refresh(refreshToken string) (*TokenPair, *models.User, error) {
+	var (
+		pair   *TokenPair
+		user   *models.User
+		reused bool
+	)
+	err := s.store.Tx(func(tx store.Repository) error {
+		token, err := tx.GetRefreshToken(hashToken(refreshToken))
+		if errors.Is(err, store.ErrRefreshTokenNotFound) {
+			return ErrInvalidRefreshToken
+		}
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if !token.RevokedAt.IsZero() || now.After(token.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+		if !token.UsedAt.IsZero() {
+			// The revocation has to be committed, so report the reuse
+			// only after the transaction.
+			reused = true
+			return revokeFamily(tx, token.FamilyID, now)
+		}
+
+		user, err = tx.GetUserByID(token.UserID)
+		if err != nil || !user.DeactivatedAt.IsZero() {
+			return ErrInvalidRefreshToken
+		}
+		token.UsedAt = now
+		if err := tx.UpdateRefreshToken(token); err != nil {
+			return err
+		}
+		if err := extendSession(tx, token.FamilyID, now, now.Add(s.refreshTTL)); err != nil {
+			return err
+		}
+		pair, err = s.issue(tx, user, token.FamilyID)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if reused {
+		return nil, nil, ErrRefreshTokenReused
+	}
+	return pair, user, nil
+}
+
+// IssueChallenge returns a challenge token for a user who has given the
+// right password and still has to give a second factor.
+func (s *TokenService) IssueChallenge(user *models.User) (*Challenge, error) {
+	now := time.Now()
+	token, err := s.keys.Sign(&challengeClaims{
+		UserID: user.ID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{challengeAudience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(challengeTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Challenge{Token: token, ExpiresIn: int(challengeTTL / time.Second)}, nil
+}
+
+// VerifyChallenge returns the ID of the user a challenge token was issued
+// to.
+func (s *TokenService) VerifyChallenge(token string) (int, error) {
+	claims := &challengeClaims{}
+	if err := s.keys.Parse(token, claims); err != nil {
+		return 0, ErrInvalidChallenge
+	}
+	if !slices.Contains(claims.Audience, challengeAudience) || claims.UserID == 0 {
+		return 0, ErrInvalidChallenge
+	}
+	return claims.UserID, nil
+}
+
+// RevokeSession logs a session out. Its refresh token and every access
+// token issued for it stop working right away.
+func (s *TokenService) RevokeSession(sessionID string) error {
+	return s.store.Tx(func(tx store.Repository) error {
+		return revokeFamily(tx, sessionID, time.Now())
+	})
+}
+
+// Run deletes expired refresh tokens and sessions every interval until the
+// process exits. A zero interval disables the cleanup.
+func (s *TokenService) Run(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		now := time.Now()
+		n, err := s.store.DeleteExpiredRefreshTokens(now)
+		if err != nil {
+			log.Printf("refresh token cleanup failed: %v", err)
+			continue
+		}
+		if n > 0 {
+			log.Printf("deleted %d expired refresh tokens", n)
+		}
+		if _, err := s.store.DeleteExpiredSessions(now); err != nil {
+			log.Printf("session cleanup failed: %v", err)
+		}
+	}
+}
+
+func (s *TokenService) issue(tx store.Repository, user *models.User, familyID string) (*TokenPair, error) {
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	err = tx.CreateRefreshToken(&models.RefreshToken{
+		TokenHash: hashToken(refreshToken),
+		FamilyID:  familyID,
+		UserID:    user.ID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.refreshTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := s.keys.GenerateToken(user.ID, user.OrgID, user.Username, user.Role, familyID, s.accessTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(s.accessTTL / time.Second),
+	}, nil
+}
+
+func revokeFamily(tx store.Repository, familyID string, at time.Time) error {
+	tokens, err := tx.GetRefreshTokensByFamily(familyID)
+	if err != nil {
+		return err
+	}
+	for _, token := range tokens {
+		if token.RevokedAt.IsZero() {
+			token.RevokedAt = at
+			if err := tx.UpdateRefreshToken(&token); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// revokeUserSessions revokes every session of the user, for when the
+// account is locked or its password changes.
+func revokeUserSessions(tx store.Repository, userID int, at time.Time) error {
+	tokens, err := tx.GetRefreshTokensByUser(userID)
+	if err != nil {
+		return err
+	}
+	for _, token := range tokens {
+		if token.RevokedAt.IsZero() {
+			token.RevokedAt = at
+			if err := tx.UpdateRefreshToken(&token); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// randomToken returns n random bytes, base64url encoded.
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken is how bearer secrets are looked up in the store. They are
+// random and long, so a plain SHA-256 is enough; there is nothing to gain
+// from a slow KDF.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/YahyaCengiz/todo-v2/authz"
+	"github.com/YahyaCengiz/todo-v2/models"
+	"github.com/YahyaCengiz/todo-v2/store"
+)
+
+// Errors for restoring what is not in the trash, or not yet restorable.
+var (
+	ErrListNotDeleted = errors.New("todo list is not deleted")
+	ErrListDeleted    = errors.New("todo list is deleted, restore the list first")
+	ErrItemNotDeleted = errors.New("todo item is not deleted")
+)
+
+// Trash holds the soft-deleted records a user can still restore. Items of a
+// deleted list are listed under that list, not in Items.
+type Trash struct {
+	Lists []*models.TodoList `json:"lists"`
+	Items []models.TodoItem  `json:"items"`
+}
+
+func (s *TodoService) GetTrash(sub authz.Subject) (*Trash, error) {
+	lists, err := s.readableLists(sub)
+	if err != nil {
+		return nil, err
+	}
+
+	trash := &Trash{Lists: make([]*models.TodoList, 0), Items: make([]models.TodoItem, 0)}
+	for _, list := range lists {
+		if !list.DeletedAt.IsZero() {
+			// Only those who could restore a deleted list get to see it.
+			if !s.canOnList(sub, authz.ListsWrite, list, list.UserID) {
+				continue
+			}
+			deletedWithList := make([]models.TodoItem, 0)
+			for _, item := range list.TodoItems {
+				if item.DeletedAt.Equal(list.DeletedAt) {
+					deletedWithList = append(deletedWithList, item)
+				}
+			}
+			list.TodoItems = deletedWithList
+			trash.Lists = append(trash.Lists, list)
+			continue
+		}
+		for _, item := range list.TodoItems {
+			if !item.DeletedAt.IsZero() && s.canOnList(sub, authz.ListsRead, list, item.UserID) {
+				trash.Items = append(trash.Items, item)
+			}
+		}
+	}
+	return trash, nil
+}
+
+// RestoreTodoList undeletes a list and the items that were deleted with it.
+func (s *TodoService) RestoreTodoList(id int, sub authz.Subject) (*models.TodoList, error) {
+	var todoList *models.TodoList
+	err := s.store.Tx(func(tx store.Repository) error {
+		var err error
+		todoList, err = tx.GetTodoList(id)
+		if err != nil {
+			return err
+		}
+		if err := s.authorizeOnList(sub, authz.ListsWrite, todoList, todoList.UserID); err != nil {
+			return err
+		}
+		if todoList.DeletedAt.IsZero() {
+			return ErrListNotDeleted
+		}
+
+		deletedAt := todoList.DeletedAt
+		todoList.DeletedAt = time.Time{}
+		todoList.UpdatedAt = time.Now()
+		if err := tx.UpdateTodoList(todoList); err != nil {
+			return err
+		}
+		for _, item := range todoList.TodoItems {
+			if item.DeletedAt.Equal(deletedAt) {
+				item.DeletedAt = time.Time{}
+				if err := tx.UpdateTodoItem(id, &item); err != nil {
+					return err
+				}
+			}
+		}
+		if err := updateCompletionPercentage(tx, id); err != nil {
+			return err
+		}
+		todoList, err = tx.GetTodoList(id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return todoList, nil
+}
+
+// RestoreTodoItem undeletes a single item. Its list has to be restored first
+// if it was deleted too.
+func (s *TodoService) RestoreTodoItem(listID, itemID int, sub authz.Subject) (*models.TodoItem, error) {
+	var todoItem *models.TodoItem
+	err := s.store.Tx(func(tx store.Repository) error {
+		todoList, err := tx.GetTodoList(listID)
+		if err != nil {
+			return err
+		}
+		todoItem, err = tx.GetTodoItem(listID, itemID)
+		if err != nil {
+			return err
+		}
+		if err := s.authorizeOnList(sub, authz.ItemsWrite, todoList, todoItem.UserID); err != nil {
+			return err
+		}
+		if !todoList.DeletedAt.IsZero() {
+			return ErrListDeleted
+		}
+		if todoItem.DeletedAt.IsZero() {
+			return ErrItemNotDeleted
+		}
+
+		todoItem.DeletedAt = time.Time{}
+		todoItem.UpdatedAt = time.Now()
+		if err := tx.UpdateTodoItem(listID, todoItem); err != nil {
+			return err
+		}
+		return updateCompletionPercentage(tx, listID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return todoItem, nil
+}
@@ -0,0 +1,260 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/YahyaCengiz/todo-v2/authz"
+	"github.com/YahyaCengiz/todo-v2/models"
+	"github.com/YahyaCengiz/todo-v2/store"
+)
+
+// The methods below back the admin user API. The controller checks for
+// users:manage; the service keeps admins to the accounts they may manage
+// (see checkManages) and from locking themselves out.
+
+// ListUsers returns the accounts of the organization orgID, or of the
+// subject's own if orgID is 0.
+func (s *UserService) ListUsers(orgID int, sub authz.Subject) ([]models.User, error) {
+	if orgID == 0 {
+		orgID = sub.OrgID
+	}
+	if err := s.checkOrg(orgID, sub); err != nil {
+		return nil, err
+	}
+	users, err := s.store.GetUsers()
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(users, func(u models.User) bool { return u.OrgID != orgID }), nil
+}
+
+// CreateUser adds an account to the organization orgID, or to the
+// subject's own if orgID is 0, under the same username, password and email
+// rules as Register. The role may be any defined one that gives nothing
+// the subject does not have.
+func (s *UserService) CreateUser(username, password, role, email string, orgID int, sub authz.Subject) (*models.User, error) {
+	if err := validateUsername(username); err != nil {
+		return nil, err
+	}
+	if err := validatePassword(password); err != nil {
+		return nil, err
+	}
+	if err := s.validateRole(role, sub); err != nil {
+		return nil, err
+	}
+	if orgID == 0 {
+		orgID = sub.OrgID
+	}
+	if err := s.checkOrg(orgID, sub); err != nil {
+		return nil, err
+	}
+	email, err := optionalEmail(email)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	user := &models.User{
+		OrgID:    orgID,
+		Username: username,
+		Password: hash,
+		Role:     role,
+		Email:    email,
+	}
+	if err := s.store.AddUser(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// SetRole changes the user's role. It applies to tokens already issued to
+// the user from their next request on.
+func (s *UserService) SetRole(id int, role string, sub authz.Subject) (*models.User, error) {
+	if err := s.validateRole(role, sub); err != nil {
+		return nil, err
+	}
+	if id == sub.UserID {
+		return nil, fmt.Errorf("%w: you cannot change your own role", ErrInvalidInput)
+	}
+	return s.manageUser(id, sub, func(tx store.Repository, user *models.User) error {
+		user.Role = role
+		return nil
+	})
+}
+
+// ResetPassword sets a new password and logs the user out everywhere.
+// Accounts that log in through SSO cannot be given one.
+func (s *UserService) ResetPassword(id int, password string, sub authz.Subject) (*models.User, error) {
+	if err := validatePassword(password); err != nil {
+		return nil, err
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	return s.manageUser(id, sub, func(tx store.Repository, user *models.User) error {
+		if user.SSOSubject != "" {
+			return fmt.Errorf("%w: the account logs in through SSO and has no password", ErrInvalidInput)
+		}
+		user.Password = hash
+		user.PasswordVersion++
+		return revokeUserSessions(tx, id, time.Now())
+	})
+}
+
+// Deactivate locks the account and ends its sessions. API keys stay but
+// are refused until the account is reactivated.
+func (s *UserService) Deactivate(id int, sub authz.Subject) (*models.User, error) {
+	if id == sub.UserID {
+		return nil, fmt.Errorf("%w: you cannot deactivate your own account", ErrInvalidInput)
+	}
+	return s.manageUser(id, sub, func(tx store.Repository, user *models.User) error {
+		if !user.DeactivatedAt.IsZero() {
+			return nil
+		}
+		now := time.Now()
+		user.DeactivatedAt = now
+		return revokeUserSessions(tx, id, now)
+	})
+}
+
+// Unlock lifts a login lockout of the user before it runs out.
+func (s *UserService) Unlock(id int, sub authz.Subject) (*models.User, error) {
+	user, err := s.store.GetUserByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkManages(user, sub); err != nil {
+		return nil, err
+	}
+	s.limiter.Unlock(user.Username)
+	return user, nil
+}
+
+func (s *UserService) Reactivate(id int, sub authz.Subject) (*models.User, error) {
+	return s.manageUser(id, sub, func(tx store.Repository, user *models.User) error {
+		user.DeactivatedAt = time.Time{}
+		return nil
+	})
+}
+
+// DeleteUser removes the account, ends its sessions and takes it off the
+// lists shared with it. Lists the user owns are kept for admins to deal
+// with.
+func (s *UserService) DeleteUser(id int, sub authz.Subject) error {
+	if id == sub.UserID {
+		return fmt.Errorf("%w: you cannot delete your own account", ErrInvalidInput)
+	}
+	return s.store.Tx(func(tx store.Repository) error {
+		user, err := tx.GetUserByID(id)
+		if err != nil {
+			return err
+		}
+		if err := s.checkManages(user, sub); err != nil {
+			return err
+		}
+		if err := revokeUserSessions(tx, id, time.Now()); err != nil {
+			return err
+		}
+		shared, err := tx.GetTodoListsByMember(id)
+		if err != nil {
+			return err
+		}
+		for _, todoList := range shared {
+			todoList.Members = slices.DeleteFunc(todoList.Members, func(m models.ListMember) bool { return m.UserID == id })
+			if err := tx.UpdateTodoList(todoList); err != nil {
+				return err
+			}
+		}
+		return tx.DeleteUser(id)
+	})
+}
+
+// CheckAccount implements middleware.AccountChecker.
+func (s *UserService) CheckAccount(userID int) (string, bool, error) {
+	user, err := s.store.GetUserByID(userID)
+	if errors.Is(err, store.ErrUserNotFound) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return user.Role, user.DeactivatedAt.IsZero(), nil
+}
+
+func (s *UserService) updateUser(id int, change func(tx store.Repository, user *models.User) error) (*models.User, error) {
+	var user *models.User
+	err := s.store.Tx(func(tx store.Repository) error {
+		var err error
+		user, err = tx.GetUserByID(id)
+		if err != nil {
+			return err
+		}
+		if err := change(tx, user); err != nil {
+			return err
+		}
+		return tx.UpdateUser(*user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// manageUser is updateUser for an account the subject administers.
+func (s *UserService) manageUser(id int, sub authz.Subject, change func(tx store.Repository, user *models.User) error) (*models.User, error) {
+	return s.updateUser(id, func(tx store.Repository, user *models.User) error {
+		if err := s.checkManages(user, sub); err != nil {
+			return err
+		}
+		return change(tx, user)
+	})
+}
+
+// checkManages allows sub to administer accounts of its own organization,
+// or of any with orgs:manage, as long as their role gives nothing sub does
+// not have. Accounts of other organizations are reported as not found.
+func (s *UserService) checkManages(user *models.User, sub authz.Subject) error {
+	if user.OrgID != sub.OrgID && !s.authz.Can(sub, authz.OrgsManage) {
+		return store.ErrUserNotFound
+	}
+	if !s.authz.Covers(sub, user.Role) {
+		return fmt.Errorf("%w: the user's role has permissions you do not have", authz.ErrForbidden)
+	}
+	return nil
+}
+
+// checkOrg allows sub to work with the accounts of its own organization,
+// or of any existing one with orgs:manage.
+func (s *UserService) checkOrg(orgID int, sub authz.Subject) error {
+	if orgID == sub.OrgID {
+		return nil
+	}
+	if !s.authz.Can(sub, authz.OrgsManage) {
+		return authz.ErrForbidden
+	}
+	if _, err := s.store.GetOrganization(orgID); errors.Is(err, store.ErrOrganizationNotFound) {
+		return fmt.Errorf("%w: unknown organization %d", ErrInvalidInput, orgID)
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// validateRole checks that the role exists and that sub could give it to
+// someone.
+func (s *UserService) validateRole(role string, sub authz.Subject) error {
+	if !s.authz.HasRole(role) {
+		return fmt.Errorf("%w: unknown role %q", ErrInvalidInput, role)
+	}
+	if !s.authz.Covers(sub, role) {
+		return fmt.Errorf("%w: the role has permissions you do not have", authz.ErrForbidden)
+	}
+	return nil
+}
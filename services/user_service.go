@@ -1,35 +1,152 @@
 package services
 
 import (
-	"fmt"
+	"errors"
+	"log"
 
+	"github.com/YahyaCengiz/todo-v2/authz"
 	"github.com/YahyaCengiz/todo-v2/models"
 	"github.com/YahyaCengiz/todo-v2/store"
+	"golang.org/x/crypto/bcrypt"
 )
 
+// ErrInvalidCredentials is the only reason Login gives for turning a user
+// away, apart from throttling, so that responses do not reveal which
+// usernames exist or are deactivated.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
 type UserService struct {
-	store *store.Store
+	store   store.Repository
+	authz   *authz.Authorizer
+	limiter *LoginLimiter
+	// totpIssuer names the service in authenticator apps.
+	totpIssuer string
 }
 
-func NewUserService(store *store.Store) *UserService {
+func NewUserService(store store.Repository, authorizer *authz.Authorizer, limiter *LoginLimiter, totpIssuer string) *UserService {
 	return &UserService{
-		store: store,
+		store:      store,
+		authz:      authorizer,
+		limiter:    limiter,
+		totpIssuer: totpIssuer,
 	}
 }
 
-func (s *UserService) Login(username, password string) (*models.User, error) {
+// Login checks the password of a user connecting from ip. Failed attempts
+// are throttled per username and per address; see LoginLimiter. For users
+// with a second factor, earlier failures are kept until LoginSecondFactor
+// succeeds, so knowing the password does not give unlimited guesses at the
+// code.
+func (s *UserService) Login(username, password, ip string) (*models.User, error) {
 	if username == "" || password == "" {
-		return nil, fmt.Errorf("username and password are required")
+		return nil, ErrInvalidCredentials
+	}
+	if err := s.limiter.Begin(username, ip); err != nil {
+		return nil, err
 	}
 
-	for _, user := range s.store.GetUsers() {
-		if user.Username == username && user.Password == password {
-			return &user, nil
+	user, err := s.store.GetUserByUsername(username)
+	if err != nil {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	ok, rehash := checkPassword(user.Password, password)
+	if !ok || !user.DeactivatedAt.IsZero() {
+		return nil, ErrInvalidCredentials
+	}
+	if user.TOTPEnabled {
+		s.limiter.Release(username, ip)
+	} else {
+		s.limiter.Succeed(username, ip)
+	}
+	if rehash {
+		// A failed upgrade is retried on the next login; it must not keep
+		// the user out.
+		if err := s.rehashPassword(user.ID, user.Password, password); err != nil {
+			log.Printf("failed to rehash password of user %q: %v", user.Username, err)
 		}
 	}
-	return nil, fmt.Errorf("invalid credentials")
+	return user, nil
 }
 
-func (s *UserService) Register(user models.User) error {
-	return s.store.AddUser(user)
+// Register creates a regular user account in the default organization after
+// checking the username, password and optional email address against the
+// rules in validation.go.
+func (s *UserService) Register(username, password, email string) (*models.User, error) {
+	if err := validateUsername(username); err != nil {
+		return nil, err
+	}
+	if err := validatePassword(password); err != nil {
+		return nil, err
+	}
+	email, err := optionalEmail(email)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	user := &models.User{
+		OrgID:    models.DefaultOrganizationID,
+		Username: username,
+		Password: hash,
+		Role:     "user",
+		Email:    email,
+	}
+	if err := s.store.AddUser(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// SetEmail changes the address password reset links are sent to. An empty
+// email removes it.
+func (s *UserService) SetEmail(userID int, email string) (*models.User, error) {
+	email, err := optionalEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	return s.updateUser(userID, func(tx store.Repository, user *models.User) error {
+		user.Email = email
+		return nil
+	})
+}
+
+// errPasswordChanged stops a rehash when the password changed meanwhile.
+var errPasswordChanged = errors.New("password changed")
+
+// rehashPassword replaces the legacy password of user id with a hash of the
+// same password. It leaves the rest of the account alone, and the password
+// too if it is no longer legacy, so changes made since the login stand.
+func (s *UserService) rehashPassword(id int, legacy, password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	_, err = s.updateUser(id, func(tx store.Repository, user *models.User) error {
+		if user.Password != legacy {
+			return errPasswordChanged
+		}
+		user.Password = hash
+		return nil
+	})
+	if errors.Is(err, errPasswordChanged) {
+		return nil
+	}
+	return err
+}
+
+// optionalEmail normalises and checks an email address that may be left
+// empty.
+func optionalEmail(email string) (string, error) {
+	email = normalizeEmail(email)
+	if email == "" {
+		return "", nil
+	}
+	if err := validateEmail(email); err != nil {
+		return "", err
+	}
+	return email, nil
 }
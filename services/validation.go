@@ -0,0 +1,64 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// ErrInvalidInput marks errors caused by a request that breaks one of the
+// rules below, as opposed to a failure on our side.
+var ErrInvalidInput = errors.New("invalid input")
+
+const (
+	minPasswordLength = 8
+	// bcrypt ignores everything after the 72nd byte.
+	maxPasswordLength = 72
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{2,31}$`)
+
+func validateUsername(username string) error {
+	if !usernamePattern.MatchString(username) {
+		return fmt.Errorf("%w: username must be 3 to 32 letters, digits, '.', '_' or '-' and start with a letter or digit", ErrInvalidInput)
+	}
+	return nil
+}
+
+func validatePassword(password string) error {
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return fmt.Errorf("%w: password must be %d to %d bytes long", ErrInvalidInput, minPasswordLength, maxPasswordLength)
+	}
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	if !hasLetter || !hasDigit {
+		return fmt.Errorf("%w: password must contain at least one letter and one digit", ErrInvalidInput)
+	}
+	return nil
+}
+
+// validateEmail accepts a bare address such as "ann@example.com". Display
+// names and anything that would need quoting in a mail header are refused.
+func validateEmail(email string) error {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || addr.Name != "" || len(email) > 254 {
+		return fmt.Errorf("%w: %q is not a valid email address", ErrInvalidInput, email)
+	}
+	return nil
+}
+
+// normalizeEmail is applied to every address before it is stored or
+// looked up, so that lookups do not depend on case.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,96 @@
+package store
+
+import (
+	"fmt"
+
+	"github.com/YahyaCengiz/todo-v2/models"
+)
+
+// API keys, like refresh tokens, are left alone by snapshot restores.
+
+func (s *Store) CreateAPIKey(key *models.APIKey) error {
+	return s.Tx(func(tx Repository) error { return tx.CreateAPIKey(key) })
+}
+
+func (s *Store) GetAPIKey(id int) (*models.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.getAPIKey(id)
+}
+
+func (s *Store) GetAPIKeyByHash(keyHash string) (*models.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.getAPIKeyByHash(keyHash)
+}
+
+func (s *Store) GetAPIKeysByUser(userID int) ([]models.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.getAPIKeysByUser(userID), nil
+}
+
+func (s *Store) UpdateAPIKey(key *models.APIKey) error {
+	return s.Tx(func(tx Repository) error { return tx.UpdateAPIKey(key) })
+}
+
+func (s *Store) getAPIKey(id int) (*models.APIKey, error) {
+	if i := s.findAPIKey(id); i >= 0 {
+		key := s.apiKeys[i]
+		return &key, nil
+	}
+	return nil, ErrAPIKeyNotFound
+}
+
+func (s *Store) getAPIKeyByHash(keyHash string) (*models.APIKey, error) {
+	if i, ok := s.idx.apiKeys[keyHash]; ok {
+		key := s.apiKeys[i]
+		return &key, nil
+	}
+	return nil, ErrAPIKeyNotFound
+}
+
+func (s *Store) getAPIKeysByUser(userID int) []models.APIKey {
+	keys := make([]models.APIKey, 0)
+	for _, key := range s.apiKeys {
+		if key.UserID == userID {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func (tx *storeTx) CreateAPIKey(key *models.APIKey) error {
+	if _, exists := tx.s.idx.apiKeys[key.KeyHash]; exists {
+		return fmt.Errorf("api key already exists")
+	}
+	key.ID = tx.s.ids.nextKey()
+	created := *key
+	tx.apply(mutation{Op: opCreateAPIKey, APIKey: &created})
+	return nil
+}
+
+func (tx *storeTx) GetAPIKey(id int) (*models.APIKey, error) {
+	return tx.s.getAPIKey(id)
+}
+
+func (tx *storeTx) GetAPIKeyByHash(keyHash string) (*models.APIKey, error) {
+	return tx.s.getAPIKeyByHash(keyHash)
+}
+
+func (tx *storeTx) GetAPIKeysByUser(userID int) ([]models.APIKey, error) {
+	return tx.s.getAPIKeysByUser(userID), nil
+}
+
+// UpdateAPIKey changes everything but the key hash, which identifies the
+// key for as long as it exists.
+func (tx *storeTx) UpdateAPIKey(key *models.APIKey) error {
+	i := tx.s.findAPIKey(key.ID)
+	if i < 0 {
+		return ErrAPIKeyNotFound
+	}
+	updated := *key
+	updated.KeyHash = tx.s.apiKeys[i].KeyHash
+	tx.apply(mutation{Op: opUpdateAPIKey, APIKey: &updated})
+	return nil
+}
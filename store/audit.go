@@ -0,0 +1,49 @@
+package store
+
+import "github.com/YahyaCengiz/todo-v2/models"
+
+// The audit log, like API keys, is left alone by snapshot restores: a
+// restore must not erase the record of what was done before it.
+
+func (s *Store) AddAuditEntry(entry *models.AuditEntry) error {
+	return s.Tx(func(tx Repository) error { return tx.AddAuditEntry(entry) })
+}
+
+func (s *Store) GetAuditEntries(filter AuditFilter) ([]models.AuditEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.getAuditEntries(filter), nil
+}
+
+func (s *Store) getAuditEntries(filter AuditFilter) []models.AuditEntry {
+	entries := make([]models.AuditEntry, 0)
+	for _, entry := range s.auditLog {
+		if filter.OrgID != 0 && entry.OrgID != filter.OrgID {
+			continue
+		}
+		if filter.ActorID != 0 && entry.ActorID != filter.ActorID {
+			continue
+		}
+		if filter.UserID != 0 && entry.UserID != filter.UserID {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// AddAuditEntry numbers entries after the newest one. Entries are never
+// removed, so no ID counter has to be kept.
+func (tx *storeTx) AddAuditEntry(entry *models.AuditEntry) error {
+	entry.ID = 1
+	if n := len(tx.s.auditLog); n > 0 {
+		entry.ID = tx.s.auditLog[n-1].ID + 1
+	}
+	added := *entry
+	tx.apply(mutation{Op: opAddAuditEntry, AuditEntry: &added})
+	return nil
+}
+
+func (tx *storeTx) GetAuditEntries(filter AuditFilter) ([]models.AuditEntry, error) {
+	return tx.s.getAuditEntries(filter), nil
+}
@@ -0,0 +1,392 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/YahyaCengiz/todo-v2/models"
+)
+
+var (
+	ErrBackupsDisabled  = errors.New("backups are not enabled")
+	ErrSnapshotNotFound = errors.New("snapshot not found")
+)
+
+const snapshotTimeFormat = "20060102T150405.000000000Z"
+
+// Option configures a file store.
+type Option func(*Store)
+
+// WithBackups keeps the newest keep snapshots in dir, along with the journal
+// history needed to restore the store to any moment after the oldest one.
+func WithBackups(dir string, keep int) Option {
+	return func(s *Store) {
+		s.backupDir = dir
+		s.backupKeep = keep
+	}
+}
+
+// SnapshotInfo describes a snapshot in the backup directory.
+type SnapshotInfo struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	Seq       uint64    `json:"seq"`
+	Size      int64     `json:"size"`
+}
+
+// Snapshotter is implemented by backends that support snapshots and
+// point-in-time restore.
+type Snapshotter interface {
+	Snapshot() (*SnapshotInfo, error)
+	ListSnapshots() ([]SnapshotInfo, error)
+	RestoreSnapshot(name string) (*SnapshotInfo, error)
+	RestoreToTime(at time.Time) (*SnapshotInfo, error)
+}
+
+var _ Snapshotter = (*Store)(nil)
+
+// Snapshot writes the current state to a new snapshot and drops the ones
+// that fall outside the retention.
+func (s *Store) Snapshot() (*SnapshotInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.backupDir == "" {
+		return nil, ErrBackupsDisabled
+	}
+	return s.takeSnapshot()
+}
+
+func (s *Store) ListSnapshots() ([]SnapshotInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.backupDir == "" {
+		return nil, ErrBackupsDisabled
+	}
+	return s.listSnapshots()
+}
+
+// RestoreSnapshot replaces the whole store with the named snapshot. The
+// state being replaced is snapshotted first so the restore can be undone.
+func (s *Store) RestoreSnapshot(name string) (*SnapshotInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.backupDir == "" {
+		return nil, ErrBackupsDisabled
+	}
+	info, err := s.findSnapshot(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.readSnapshot(info.Name)
+	if err != nil {
+		return nil, err
+	}
+	return info, s.install(data)
+}
+
+// RestoreToTime rebuilds the store as it was at the given moment by loading
+// the nearest earlier snapshot and replaying the journal history up to it.
+func (s *Store) RestoreToTime(at time.Time) (*SnapshotInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.backupDir == "" {
+		return nil, ErrBackupsDisabled
+	}
+
+	snapshots, err := s.listSnapshots()
+	if err != nil {
+		return nil, err
+	}
+	var base *SnapshotInfo
+	for i := range snapshots {
+		if !snapshots[i].CreatedAt.After(at) {
+			base = &snapshots[i]
+		}
+	}
+	if base == nil {
+		return nil, fmt.Errorf("no snapshot taken before %s: %w", at.Format(time.RFC3339), ErrSnapshotNotFound)
+	}
+
+	data, err := s.readSnapshot(base.Name)
+	if err != nil {
+		return nil, err
+	}
+	replica := &Store{todoLists: data.TodoLists, users: data.Users, seq: data.Seq}
+	replica.ids = ids{lastList: data.LastListID, lastItem: data.LastItemID, lastUser: data.LastUserID, lastKey: data.LastKeyID}
+	replica.reindex()
+	if err := s.replayHistory(replica, at); err != nil {
+		return nil, err
+	}
+	data.TodoLists, data.Users = replica.todoLists, replica.users
+	data.LastListID, data.LastItemID, data.LastUserID, data.LastKeyID = replica.ids.lastList, replica.ids.lastItem, replica.ids.lastUser, replica.ids.lastKey
+	return base, s.install(data)
+}
+
+// install swaps in restored data and snapshots the result, so later
+// point-in-time restores never replay new history onto pre-restore state.
+func (s *Store) install(data *snapshot) error {
+	if _, err := s.takeSnapshot(); err != nil {
+		return fmt.Errorf("failed to snapshot state before restore: %w", err)
+	}
+
+	s.todoLists = data.TodoLists
+	s.users = data.Users
+	s.reindex()
+	s.adoptLegacyRecords()
+	// The counters never go back, so IDs handed out after the restored
+	// point are not reused for new records.
+	s.ids.observeList(data.LastListID)
+	s.ids.observeItem(data.LastItemID)
+	s.ids.observeUser(data.LastUserID)
+	s.ids.observeKey(data.LastKeyID)
+	s.ids.observeAll(s.todoLists, s.users, s.apiKeys)
+	s.renumberDuplicateItems()
+	if s.journal != nil {
+		if err := s.compact(); err != nil {
+			return err
+		}
+	} else if err := s.saveToFile(); err != nil {
+		return err
+	}
+
+	_, err := s.takeSnapshot()
+	return err
+}
+
+// replayHistory applies archived and live journal entries newer than the
+// replica's sequence number and not later than at.
+func (s *Store) replayHistory(replica *Store, at time.Time) error {
+	segments, err := s.historySegments()
+	if err != nil {
+		return err
+	}
+
+	var sources [][]byte
+	for _, segment := range segments {
+		raw, err := os.ReadFile(filepath.Join(s.backupDir, segment.name))
+		if err != nil {
+			return fmt.Errorf("failed to read history: %w", err)
+		}
+		sources = append(sources, raw)
+	}
+	if s.journal != nil {
+		raw, err := s.journal.contents()
+		if err != nil {
+			return err
+		}
+		sources = append(sources, raw)
+	}
+
+	errStop := errors.New("stop")
+	for _, raw := range sources {
+		_, err := scanJournal(bytes.NewReader(raw), func(entry journalEntry, mutations []mutation) error {
+			if entry.Seq <= replica.seq {
+				return nil
+			}
+			if entry.Time.After(at) {
+				return errStop
+			}
+			for _, m := range mutations {
+				replica.apply(m)
+			}
+			replica.seq = entry.Seq
+			return nil
+		})
+		if errors.Is(err, errStop) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) takeSnapshot() (*SnapshotInfo, error) {
+	if err := os.MkdirAll(s.backupDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	now := time.Now().UTC()
+	name := fmt.Sprintf("store-%s-%d.json", now.Format(snapshotTimeFormat), s.seq)
+	data, err := json.MarshalIndent(s.snapshot(), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode JSON: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(s.backupDir, name), data); err != nil {
+		return nil, err
+	}
+
+	if err := s.rotate(); err != nil {
+		return nil, err
+	}
+	return &SnapshotInfo{Name: name, CreatedAt: now, Seq: s.seq, Size: int64(len(data))}, nil
+}
+
+// rotate keeps the newest backupKeep snapshots and deletes history that is
+// older than the oldest snapshot left.
+func (s *Store) rotate() error {
+	snapshots, err := s.listSnapshots()
+	if err != nil {
+		return err
+	}
+	if s.backupKeep > 0 && len(snapshots) > s.backupKeep {
+		for _, old := range snapshots[:len(snapshots)-s.backupKeep] {
+			if err := os.Remove(filepath.Join(s.backupDir, old.Name)); err != nil {
+				return fmt.Errorf("failed to remove snapshot: %w", err)
+			}
+		}
+		snapshots = snapshots[len(snapshots)-s.backupKeep:]
+	}
+	if len(snapshots) == 0 {
+		return nil
+	}
+
+	oldest := snapshots[0].Seq
+	segments, err := s.historySegments()
+	if err != nil {
+		return err
+	}
+	for _, segment := range segments {
+		if segment.lastSeq <= oldest {
+			if err := os.Remove(filepath.Join(s.backupDir, segment.name)); err != nil {
+				return fmt.Errorf("failed to remove history: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// archiveJournal copies the journal into the history before it is reset.
+func (s *Store) archiveJournal() error {
+	if s.backupDir == "" || s.journal == nil || s.journal.size == 0 {
+		return nil
+	}
+	raw, err := s.journal.contents()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.backupDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	name := fmt.Sprintf("history-%020d.jsonl", s.seq)
+	return writeFileAtomic(filepath.Join(s.backupDir, name), raw)
+}
+
+func (s *Store) listSnapshots() ([]SnapshotInfo, error) {
+	entries, err := os.ReadDir(s.backupDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []SnapshotInfo{}, nil
+		}
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+
+	snapshots := make([]SnapshotInfo, 0)
+	for _, entry := range entries {
+		info, ok := parseSnapshotName(entry.Name())
+		if !ok {
+			continue
+		}
+		if fi, err := entry.Info(); err == nil {
+			info.Size = fi.Size()
+		}
+		snapshots = append(snapshots, info)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.Before(snapshots[j].CreatedAt)
+	})
+	return snapshots, nil
+}
+
+func (s *Store) findSnapshot(name string) (*SnapshotInfo, error) {
+	snapshots, err := s.listSnapshots()
+	if err != nil {
+		return nil, err
+	}
+	for i := range snapshots {
+		if snapshots[i].Name == name {
+			return &snapshots[i], nil
+		}
+	}
+	return nil, ErrSnapshotNotFound
+}
+
+func (s *Store) readSnapshot(name string) (*snapshot, error) {
+	raw, err := os.ReadFile(filepath.Join(s.backupDir, name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	var data snapshot
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+	if data.TodoLists == nil {
+		data.TodoLists = make([]models.TodoList, 0)
+	}
+	if data.Users == nil {
+		data.Users = make([]models.User, 0)
+	}
+	return &data, nil
+}
+
+type historySegment struct {
+	name    string
+	lastSeq uint64
+}
+
+func (s *Store) historySegments() ([]historySegment, error) {
+	entries, err := os.ReadDir(s.backupDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+
+	var segments []historySegment
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, "history-") || !strings.HasSuffix(name, ".jsonl") {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, "history-"), ".jsonl"), 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, historySegment{name: name, lastSeq: seq})
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].lastSeq < segments[j].lastSeq })
+	return segments, nil
+}
+
+func parseSnapshotName(name string) (SnapshotInfo, bool) {
+	if !strings.HasPrefix(name, "store-") || !strings.HasSuffix(name, ".json") {
+		return SnapshotInfo{}, false
+	}
+	stamp, seqStr, ok := strings.Cut(strings.TrimSuffix(strings.TrimPrefix(name, "store-"), ".json"), "-")
+	if !ok {
+		return SnapshotInfo{}, false
+	}
+	createdAt, err := time.Parse(snapshotTimeFormat, stamp)
+	if err != nil {
+		return SnapshotInfo{}, false
+	}
+	seq, err := strconv.ParseUint(seqStr, 10, 64)
+	if err != nil {
+		return SnapshotInfo{}, false
+	}
+	return SnapshotInfo{Name: name, CreatedAt: createdAt, Seq: seq}, true
+}
@@ -0,0 +1,46 @@
+package store
+
+import (
+	"slices"
+
+	"github.com/YahyaCengiz/todo-v2/models"
+)
+
+// The store never hands out pointers into its own slices. Callers get deep
+// copies they are free to modify, and changes only reach the store through
+// the explicit update methods.
+
+func copyList(todoList *models.TodoList) *models.TodoList {
+	c := *todoList
+	c.TodoItems = make([]models.TodoItem, len(todoList.TodoItems))
+	copy(c.TodoItems, todoList.TodoItems)
+	c.Members = slices.Clone(todoList.Members)
+	return &c
+}
+
+// listFields copies a list without its items.
+func listFields(todoList *models.TodoList) *models.TodoList {
+	fields := *todoList
+	fields.TodoItems = nil
+	fields.Members = slices.Clone(todoList.Members)
+	return &fields
+}
+
+func copyItem(todoItem *models.TodoItem) *models.TodoItem {
+	c := *todoItem
+	return &c
+}
+
+func copyUser(user *models.User) *models.User {
+	c := *user
+	c.RecoveryCodes = slices.Clone(user.RecoveryCodes)
+	return &c
+}
+
+func copyUsers(users []models.User) []models.User {
+	c := make([]models.User, len(users))
+	for i := range users {
+		c[i] = *copyUser(&users[i])
+	}
+	return c
+}
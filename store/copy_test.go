@@ -0,0 +1,127 @@
+package store
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/YahyaCengiz/todo-v2/models"
+)
+
+// TestConcurrentReadersAndWriters writes lists and items while readers
+// change every copy they are handed; run it with -race. None of the
+// readers' changes may reach the store, and every write must, also after
+// closing and reopening it.
+func TestConcurrentReadersAndWriters(t *testing.T) {
+	const (
+		writers = 8
+		readers = 4
+		lists   = 15
+	)
+	path := filepath.Join(t.TempDir(), "store.json")
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		writersWG, readersWG sync.WaitGroup
+		done                 = make(chan struct{})
+	)
+	for w := 0; w < writers; w++ {
+		writersWG.Add(1)
+		go func(w int) {
+			defer writersWG.Done()
+			for n := 0; n < lists; n++ {
+				todoList := &models.TodoList{Name: "list", UserID: w + 1, OrgID: models.DefaultOrganizationID}
+				if err := s.CreateTodoList(todoList); err != nil {
+					t.Errorf("writer %d: %v", w, err)
+					return
+				}
+				for _, content := range []string{"first", "second"} {
+					item := &models.TodoItem{TodoListID: todoList.ID, Content: content}
+					if err := s.CreateTodoItem(item); err != nil {
+						t.Errorf("writer %d: %v", w, err)
+						return
+					}
+				}
+			}
+		}(w)
+	}
+	for r := 0; r < readers; r++ {
+		readersWG.Add(1)
+		go func(r int) {
+			defer readersWG.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				all, err := s.GetTodoListsByOrg(models.DefaultOrganizationID)
+				if err != nil {
+					t.Errorf("reader %d: %v", r, err)
+					return
+				}
+				owned, err := s.GetTodoListsByUser(r + 1)
+				if err != nil {
+					t.Errorf("reader %d: %v", r, err)
+					return
+				}
+				for _, todoList := range append(all, owned...) {
+					todoList.Name = "changed"
+					for i := range todoList.TodoItems {
+						todoList.TodoItems[i].Content = "changed"
+					}
+					todoList.TodoItems = append(todoList.TodoItems, models.TodoItem{Content: "changed"})
+				}
+				if len(all) > 0 {
+					if todoList, err := s.GetTodoList(all[0].ID); err == nil && len(todoList.TodoItems) > 0 {
+						todoList.TodoItems[0].IsCompleted = true
+					}
+				}
+			}
+		}(r)
+	}
+	writersWG.Wait()
+	close(done)
+	readersWG.Wait()
+
+	check := func(s *Store) {
+		t.Helper()
+		all, err := s.GetTodoListsByOrg(models.DefaultOrganizationID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(all) != writers*lists {
+			t.Fatalf("store has %d lists, want %d", len(all), writers*lists)
+		}
+		for i, todoList := range all {
+			if todoList.ID != i+1 {
+				t.Errorf("list %d has ID %d", i+1, todoList.ID)
+			}
+			if todoList.Name != "list" {
+				t.Errorf("list %d is named %q through a copy", todoList.ID, todoList.Name)
+			}
+			if len(todoList.TodoItems) != 2 {
+				t.Errorf("list %d has %d items, want 2", todoList.ID, len(todoList.TodoItems))
+			}
+			for _, item := range todoList.TodoItems {
+				if item.Content == "changed" || item.IsCompleted {
+					t.Errorf("item %d of list %d was changed through a copy", item.ID, todoList.ID)
+				}
+			}
+		}
+	}
+	check(s)
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	check(reopened)
+}
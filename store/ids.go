@@ -0,0 +1,89 @@
+package store
+
+import "github.com/YahyaCengiz/todo-v2/models"
+
+// ids hands out list, item, user and API key IDs. The counters only move
+// forward and are saved with the data, so an ID is never handed out twice,
+// not even after the record that held it has been purged. Item IDs are
+// unique across all lists, not just within one.
+type ids struct {
+	lastList int
+	lastItem int
+	lastUser int
+	lastKey  int
+}
+
+func (c *ids) nextList() int { return c.lastList + 1 }
+
+func (c *ids) nextItem() int { return c.lastItem + 1 }
+
+func (c *ids) nextUser() int { return c.lastUser + 1 }
+
+func (c *ids) nextKey() int { return c.lastKey + 1 }
+
+func (c *ids) observeList(id int) {
+	if id > c.lastList {
+		c.lastList = id
+	}
+}
+
+func (c *ids) observeItem(id int) {
+	if id > c.lastItem {
+		c.lastItem = id
+	}
+}
+
+func (c *ids) observeUser(id int) {
+	if id > c.lastUser {
+		c.lastUser = id
+	}
+}
+
+func (c *ids) observeKey(id int) {
+	if id > c.lastKey {
+		c.lastKey = id
+	}
+}
+
+// observeAll raises the counters past every ID in lists. Files written
+// before the counters existed only have the data to go by.
+func (c *ids) observeAll(lists []models.TodoList, users []models.User, keys []models.APIKey) {
+	for i := range lists {
+		c.observeList(lists[i].ID)
+		for j := range lists[i].TodoItems {
+			c.observeItem(lists[i].TodoItems[j].ID)
+		}
+	}
+	for i := range users {
+		c.observeUser(users[i].ID)
+	}
+	for i := range keys {
+		c.observeKey(keys[i].ID)
+	}
+}
+
+// renumberDuplicateItems gives a fresh ID to every item whose ID an earlier
+// item already has. Files written before item IDs were unique across lists
+// numbered each list's items from 1. The first item keeps its ID, so the
+// oldest list keeps all of them. It reports whether any item changed;
+// callers then save the store right away, since journal entries from
+// before refer to items by their old IDs.
+func (s *Store) renumberDuplicateItems() bool {
+	seen := make(map[int]bool)
+	changed := false
+	for i := range s.todoLists {
+		for j := range s.todoLists[i].TodoItems {
+			item := &s.todoLists[i].TodoItems[j]
+			if seen[item.ID] {
+				item.ID = s.ids.nextItem()
+				s.ids.observeItem(item.ID)
+				changed = true
+			}
+			seen[item.ID] = true
+		}
+	}
+	if changed {
+		s.reindex()
+	}
+	return changed
+}
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// TestRenumberDuplicateItems opens a file from before item IDs were unique
+// across lists, in which every list numbers its items from 1.
+func TestRenumberDuplicateItems(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	legacy := `{
+  "todo_lists": [
+    {"id": 1, "name": "a", "user_id": 1, "todo_items": [{"id": 1, "todo_list_id": 1}, {"id": 2, "todo_list_id": 1}]},
+    {"id": 2, "name": "b", "user_id": 1, "todo_items": [{"id": 1, "todo_list_id": 2}, {"id": 2, "todo_list_id": 2}, {"id": 3, "todo_list_id": 2}]}
+  ],
+  "users": [{"id": 1, "username": "user1", "password": "pass1", "role": "user"}]
+}`
+	if err := os.WriteFile(path, []byte(legacy), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	itemIDs := func(s *Store, listID int) []int {
+		t.Helper()
+		todoList, err := s.GetTodoList(listID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []int
+		for _, item := range todoList.TodoItems {
+			ids = append(ids, item.ID)
+		}
+		return ids
+	}
+
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := itemIDs(s, 1); !slices.Equal(got, []int{1, 2}) {
+		t.Errorf("first list has items %v, want them kept as [1 2]", got)
+	}
+	if got := itemIDs(s, 2); !slices.Equal(got, []int{4, 5, 3}) {
+		t.Errorf("second list has items %v, want the duplicates renumbered to [4 5 3]", got)
+	}
+	if _, err := s.GetTodoItem(2, 4); err != nil {
+		t.Errorf("renumbered item is not indexed: %v", err)
+	}
+	if got := s.ids.nextItem(); got != 6 {
+		t.Errorf("next item ID is %d, want 6", got)
+	}
+
+	// The new IDs are saved on open, without waiting for a compaction.
+	s.journal.close()
+	reopened, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if got := itemIDs(reopened, 2); !slices.Equal(got, []int{4, 5, 3}) {
+		t.Errorf("after reopening, the second list has items %v, want [4 5 3]", got)
+	}
+}
@@ -0,0 +1,229 @@
+package store
+
+import "github.com/YahyaCengiz/todo-v2/models"
+
+type itemKey struct {
+	listID int
+	itemID int
+}
+
+type ssoKey struct {
+	issuer  string
+	subject string
+}
+
+// index maps IDs to positions in the store slices so lookups do not scan
+// the data. It is maintained by apply and rebuilt whenever the slices are
+// replaced wholesale.
+type index struct {
+	lists    map[int]int     // list ID -> position in todoLists
+	items    map[itemKey]int // list and item ID -> position in TodoItems
+	owners   map[int][]int   // user ID -> IDs of the lists they own
+	shared   map[int][]int   // user ID -> IDs of the lists shared with them
+	orgLists map[int][]int   // organization ID -> IDs of its lists
+	users    map[string]int  // username -> position in users
+	userIDs  map[int][]int   // user ID -> positions in users, ascending
+	emails   map[string]int  // email address -> position in users
+	sso      map[ssoKey]int  // SSO identity -> position in users
+
+	tokens   map[string]int      // token hash -> position in tokens
+	families map[string][]string // family ID -> hashes of its tokens
+	sessions map[string]int      // session ID -> position in sessions
+	apiKeys  map[string]int      // key hash -> position in apiKeys
+	keyIDs   map[int]int         // API key ID -> position in apiKeys
+}
+
+func (s *Store) reindex() {
+	s.idx = index{
+		lists:    make(map[int]int, len(s.todoLists)),
+		items:    make(map[itemKey]int),
+		owners:   make(map[int][]int),
+		shared:   make(map[int][]int),
+		orgLists: make(map[int][]int),
+		users:    make(map[string]int, len(s.users)),
+		userIDs:  make(map[int][]int, len(s.users)),
+		emails:   make(map[string]int),
+		sso:      make(map[ssoKey]int),
+
+		tokens:   make(map[string]int, len(s.tokens)),
+		families: make(map[string][]string),
+		sessions: make(map[string]int, len(s.sessions)),
+		apiKeys:  make(map[string]int, len(s.apiKeys)),
+		keyIDs:   make(map[int]int, len(s.apiKeys)),
+	}
+	for i := range s.todoLists {
+		s.indexList(i)
+		for j := range s.todoLists[i].TodoItems {
+			s.indexItem(i, j)
+		}
+	}
+	for i := range s.users {
+		s.indexUser(i)
+	}
+	for i := range s.tokens {
+		s.indexToken(i)
+	}
+	for i := range s.sessions {
+		s.idx.sessions[s.sessions[i].ID] = i
+	}
+	for i := range s.apiKeys {
+		s.indexAPIKey(i)
+	}
+}
+
+func (s *Store) indexList(i int) {
+	todoList := &s.todoLists[i]
+	s.idx.lists[todoList.ID] = i
+	s.idx.owners[todoList.UserID] = append(s.idx.owners[todoList.UserID], todoList.ID)
+	s.idx.orgLists[todoList.OrgID] = append(s.idx.orgLists[todoList.OrgID], todoList.ID)
+	for _, member := range todoList.Members {
+		s.idx.shared[member.UserID] = append(s.idx.shared[member.UserID], todoList.ID)
+	}
+}
+
+func (s *Store) indexItem(i, j int) {
+	s.idx.items[itemKey{s.todoLists[i].ID, s.todoLists[i].TodoItems[j].ID}] = j
+}
+
+// indexUser keeps the first account registered under a username, which is
+// the one logins have always matched, and likewise for email addresses and
+// SSO identities. Users are only ever appended, so positions per ID stay
+// in ascending order.
+func (s *Store) indexUser(i int) {
+	user := &s.users[i]
+	if _, exists := s.idx.users[user.Username]; !exists {
+		s.idx.users[user.Username] = i
+	}
+	s.idx.userIDs[user.ID] = append(s.idx.userIDs[user.ID], i)
+	if _, exists := s.idx.emails[user.Email]; !exists && user.Email != "" {
+		s.idx.emails[user.Email] = i
+	}
+	key := ssoKey{user.SSOIssuer, user.SSOSubject}
+	if _, exists := s.idx.sso[key]; !exists && user.SSOSubject != "" {
+		s.idx.sso[key] = i
+	}
+}
+
+// reindexesUser reports whether replacing old with updated changes the
+// fields users are looked up by, other than ID and username, which updates
+// keep.
+func reindexesUser(old, updated *models.User) bool {
+	return old.Email != updated.Email || old.SSOIssuer != updated.SSOIssuer || old.SSOSubject != updated.SSOSubject
+}
+
+// moveOwner updates the owner index when a list changes hands.
+func (s *Store) moveOwner(listID, from, to int) {
+	if from == to {
+		return
+	}
+	owned := s.idx.owners[from]
+	for k, id := range owned {
+		if id == listID {
+			s.idx.owners[from] = append(owned[:k:k], owned[k+1:]...)
+			break
+		}
+	}
+	s.idx.owners[to] = append(s.idx.owners[to], listID)
+}
+
+func (s *Store) findList(id int) int {
+	if i, ok := s.idx.lists[id]; ok {
+		return i
+	}
+	return -1
+}
+
+// findItem returns the list and item positions, or -1 for the item when it
+// does not exist.
+func (s *Store) findItem(listID, itemID int) (int, int) {
+	i := s.findList(listID)
+	if i < 0 {
+		return -1, -1
+	}
+	if j, ok := s.idx.items[itemKey{listID, itemID}]; ok {
+		return i, j
+	}
+	return i, -1
+}
+
+func (s *Store) findUser(username string) (*models.User, bool) {
+	i, ok := s.idx.users[username]
+	if !ok {
+		return nil, false
+	}
+	return &s.users[i], true
+}
+
+// findUserByID returns the first user with the given ID.
+func (s *Store) findUserByID(id int) (*models.User, bool) {
+	positions := s.idx.userIDs[id]
+	if len(positions) == 0 {
+		return nil, false
+	}
+	return &s.users[positions[0]], true
+}
+
+// findUserByEmail returns the user with the given email address. An empty
+// address matches nobody.
+func (s *Store) findUserByEmail(email string) (*models.User, bool) {
+	i, ok := s.idx.emails[email]
+	if !ok {
+		return nil, false
+	}
+	return &s.users[i], true
+}
+
+// findUserBySSOSubject returns the user linked to the SSO identity. An
+// empty subject matches nobody.
+func (s *Store) findUserBySSOSubject(issuer, subject string) (*models.User, bool) {
+	i, ok := s.idx.sso[ssoKey{issuer, subject}]
+	if !ok {
+		return nil, false
+	}
+	return &s.users[i], true
+}
+
+// findUserPos returns the position of the last user with the given ID and
+// username, or -1. Only data from before user IDs were unique has more
+// than one user per ID.
+func (s *Store) findUserPos(id int, username string) int {
+	positions := s.idx.userIDs[id]
+	for k := len(positions) - 1; k >= 0; k-- {
+		if s.users[positions[k]].Username == username {
+			return positions[k]
+		}
+	}
+	return -1
+}
+
+func (s *Store) indexToken(i int) {
+	token := &s.tokens[i]
+	s.idx.tokens[token.TokenHash] = i
+	s.idx.families[token.FamilyID] = append(s.idx.families[token.FamilyID], token.TokenHash)
+}
+
+func (s *Store) findToken(tokenHash string) int {
+	if i, ok := s.idx.tokens[tokenHash]; ok {
+		return i
+	}
+	return -1
+}
+
+func (s *Store) findSession(id string) int {
+	if i, ok := s.idx.sessions[id]; ok {
+		return i
+	}
+	return -1
+}
+
+func (s *Store) indexAPIKey(i int) {
+	s.idx.apiKeys[s.apiKeys[i].KeyHash] = i
+	s.idx.keyIDs[s.apiKeys[i].ID] = i
+}
+
+func (s *Store) findAPIKey(id int) int {
+	if i, ok := s.idx.keyIDs[id]; ok {
+		return i
+	}
+	return -1
+}
@@ -0,0 +1,282 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/YahyaCengiz/todo-v2/models"
+)
+
+// The benchmarks compare the index lookups with the linear scans they
+// replaced, on stores of growing size. Each size has ten items per list and
+// ten lists per user, and every lookup asks for the last record, which is
+// the worst case of a scan.
+
+var benchSizes = []int{100, 1000, 10000}
+
+func newBenchStore(lists int) *Store {
+	s := NewMemoryStore()
+	s.todoLists = make([]models.TodoList, lists)
+	for i := range s.todoLists {
+		items := make([]models.TodoItem, 10)
+		for j := range items {
+			items[j] = models.TodoItem{ID: i*10 + j + 1, TodoListID: i + 1}
+		}
+		s.todoLists[i] = models.TodoList{
+			ID:        i + 1,
+			UserID:    i/10 + 1,
+			TodoItems: items,
+		}
+	}
+	s.users = make([]models.User, lists/10)
+	for i := range s.users {
+		s.users[i] = models.User{
+			ID:         i + 1,
+			OrgID:      models.DefaultOrganizationID,
+			Username:   fmt.Sprintf("user%d", i+1),
+			Email:      fmt.Sprintf("user%d@example.com", i+1),
+			SSOIssuer:  "https://idp.example.com",
+			SSOSubject: fmt.Sprintf("sub%d", i+1),
+		}
+	}
+	s.reindex()
+	return s
+}
+
+// scanList, scanItem, scanOwned, scanUser and scanEmail are the lookups
+// before the index. scanOwned copies the lists like GetTodoListsByUser does,
+// so only the lookups are compared.
+func (s *Store) scanList(id int) int {
+	for i := range s.todoLists {
+		if s.todoLists[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Store) scanItem(listID, itemID int) (int, int) {
+	i := s.scanList(listID)
+	if i < 0 {
+		return -1, -1
+	}
+	for j := range s.todoLists[i].TodoItems {
+		if s.todoLists[i].TodoItems[j].ID == itemID {
+			return i, j
+		}
+	}
+	return i, -1
+}
+
+func (s *Store) scanOwned(userID int) []*models.TodoList {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var lists []*models.TodoList
+	for i := range s.todoLists {
+		if s.todoLists[i].UserID == userID {
+			lists = append(lists, copyList(&s.todoLists[i]))
+		}
+	}
+	return lists
+}
+
+func (s *Store) scanUser(username string) (*models.User, bool) {
+	for i := range s.users {
+		if s.users[i].Username == username {
+			return &s.users[i], true
+		}
+	}
+	return nil, false
+}
+
+func (s *Store) scanEmail(email string) (*models.User, bool) {
+	for i := range s.users {
+		if s.users[i].Email == email {
+			return &s.users[i], true
+		}
+	}
+	return nil, false
+}
+
+func TestIndexMatchesScan(t *testing.T) {
+	s := newBenchStore(1000)
+	for _, id := range []int{1, 500, 1000, 1001} {
+		if got, want := s.findList(id), s.scanList(id); got != want {
+			t.Errorf("findList(%d) = %d, scan found %d", id, got, want)
+		}
+	}
+	for _, key := range []itemKey{{1, 1}, {500, 4995}, {1000, 10000}, {1000, 1}, {1001, 10001}} {
+		gotI, gotJ := s.findItem(key.listID, key.itemID)
+		wantI, wantJ := s.scanItem(key.listID, key.itemID)
+		if gotI != wantI || gotJ != wantJ {
+			t.Errorf("findItem(%d, %d) = %d, %d, scan found %d, %d", key.listID, key.itemID, gotI, gotJ, wantI, wantJ)
+		}
+	}
+	for _, userID := range []int{1, 100, 101} {
+		owned, err := s.GetTodoListsByUser(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(owned), len(s.scanOwned(userID)); got != want {
+			t.Errorf("user %d owns %d lists, scan found %d", userID, got, want)
+		}
+	}
+	for _, username := range []string{"user1", "user100", "nobody"} {
+		got, _ := s.findUser(username)
+		want, _ := s.scanUser(username)
+		if got != want {
+			t.Errorf("findUser(%q) = %p, scan found %p", username, got, want)
+		}
+	}
+	for _, id := range []int{1, 100, 101} {
+		got, _ := s.findUserByID(id)
+		want, _ := s.scanUser(fmt.Sprintf("user%d", id))
+		if got != want {
+			t.Errorf("findUserByID(%d) = %p, scan found %p", id, got, want)
+		}
+		got, _ = s.findUserByEmail(fmt.Sprintf("user%d@example.com", id))
+		if got != want {
+			t.Errorf("findUserByEmail for user %d = %p, scan found %p", id, got, want)
+		}
+		got, _ = s.findUserBySSOSubject("https://idp.example.com", fmt.Sprintf("sub%d", id))
+		if got != want {
+			t.Errorf("findUserBySSOSubject for user %d = %p, scan found %p", id, got, want)
+		}
+	}
+}
+
+// TestUserIndexFollowsUpdates changes the fields users are looked up by,
+// also in a transaction that is rolled back.
+func TestUserIndexFollowsUpdates(t *testing.T) {
+	s := NewMemoryStore()
+	user := &models.User{OrgID: models.DefaultOrganizationID, Username: "ann", Email: "ann@example.com"}
+	if err := s.AddUser(user); err != nil {
+		t.Fatal(err)
+	}
+	lookup := func(email string) int {
+		t.Helper()
+		found, err := s.GetUserByEmail(email)
+		if errors.Is(err, ErrUserNotFound) {
+			return 0
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return found.ID
+	}
+
+	user.Email = "ann@example.org"
+	if err := s.UpdateUser(*user); err != nil {
+		t.Fatal(err)
+	}
+	if got := lookup("ann@example.com"); got != 0 {
+		t.Errorf("old address still finds user %d", got)
+	}
+	if got := lookup("ann@example.org"); got != user.ID {
+		t.Errorf("new address finds user %d, want %d", got, user.ID)
+	}
+
+	err := s.Tx(func(tx Repository) error {
+		changed := *user
+		changed.Email = "ann@example.net"
+		if err := tx.UpdateUser(changed); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatal(err)
+	}
+	if got := lookup("ann@example.net"); got != 0 {
+		t.Errorf("rolled back address finds user %d", got)
+	}
+	if got := lookup("ann@example.org"); got != user.ID {
+		t.Errorf("after the rollback the address finds user %d, want %d", got, user.ID)
+	}
+}
+
+func BenchmarkFindList(b *testing.B) {
+	for _, n := range benchSizes {
+		s := newBenchStore(n)
+		b.Run(fmt.Sprintf("indexed/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				s.findList(n)
+			}
+		})
+		b.Run(fmt.Sprintf("scan/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				s.scanList(n)
+			}
+		})
+	}
+}
+
+func BenchmarkFindItem(b *testing.B) {
+	for _, n := range benchSizes {
+		s := newBenchStore(n)
+		itemID := n * 10
+		b.Run(fmt.Sprintf("indexed/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				s.findItem(n, itemID)
+			}
+		})
+		b.Run(fmt.Sprintf("scan/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				s.scanItem(n, itemID)
+			}
+		})
+	}
+}
+
+func BenchmarkListsByUser(b *testing.B) {
+	for _, n := range benchSizes {
+		s := newBenchStore(n)
+		userID := len(s.users)
+		b.Run(fmt.Sprintf("indexed/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				s.GetTodoListsByUser(userID)
+			}
+		})
+		b.Run(fmt.Sprintf("scan/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				s.scanOwned(userID)
+			}
+		})
+	}
+}
+
+func BenchmarkFindUser(b *testing.B) {
+	for _, n := range benchSizes {
+		s := newBenchStore(n)
+		username := s.users[len(s.users)-1].Username
+		b.Run(fmt.Sprintf("indexed/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				s.findUser(username)
+			}
+		})
+		b.Run(fmt.Sprintf("scan/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				s.scanUser(username)
+			}
+		})
+	}
+}
+
+func BenchmarkFindUserByEmail(b *testing.B) {
+	for _, n := range benchSizes {
+		s := newBenchStore(n)
+		email := s.users[len(s.users)-1].Email
+		b.Run(fmt.Sprintf("indexed/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				s.findUserByEmail(email)
+			}
+		})
+		b.Run(fmt.Sprintf("scan/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				s.scanEmail(email)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"time"
+
+	"github.com/YahyaCengiz/todo-v2/models"
+)
+
+const (
+	opCreateList = "create_list"
+	opUpdateList = "update_list"
+	opRemoveList = "remove_list"
+	opCreateItem = "create_item"
+	opUpdateItem = "update_item"
+	opRemoveItem = "remove_item"
+	opAddUser    = "add_user"
+	opUpdateUser = "update_user"
+	opRemoveUser = "remove_user"
+
+	opCreateToken = "create_token"
+	opUpdateToken = "update_token"
+	opRemoveToken = "remove_token"
+
+	opCreateSession = "create_session"
+	opUpdateSession = "update_session"
+	opRemoveSession = "remove_session"
+
+	opCreateAPIKey = "create_api_key"
+	opUpdateAPIKey = "update_api_key"
+	opRemoveAPIKey = "remove_api_key"
+
+	opAddAuditEntry    = "add_audit_entry"
+	opRemoveAuditEntry = "remove_audit_entry"
+
+	opCreateOrg = "create_org"
+	opRemoveOrg = "remove_org"
+)
+
+// mutation is a single change to the store. Every write is expressed as one
+// or more mutations so that the exact same code path applies a change live
+// and when the journal is replayed on startup.
+type mutation struct {
+	Op   string           `json:"op"`
+	List *models.TodoList `json:"list,omitempty"`
+	Item *models.TodoItem `json:"item,omitempty"`
+	User *models.User     `json:"user,omitempty"`
+
+	Token   *models.RefreshToken `json:"token,omitempty"`
+	Session *models.Session      `json:"session,omitempty"`
+	APIKey  *models.APIKey       `json:"api_key,omitempty"`
+
+	AuditEntry   *models.AuditEntry   `json:"audit_entry,omitempty"`
+	Organization *models.Organization `json:"organization,omitempty"`
+
+	// at puts a created record back at position at-1 instead of appending
+	// it. Only undo entries set it, and those are never journaled.
+	at int
+}
+
+// journalEntry is one line of the journal. The mutations of an entry are
+// applied all together or not at all.
+type journalEntry struct {
+	Seq       uint64          `json:"seq"`
+	Time      time.Time       `json:"time"`
+	CRC       uint32          `json:"crc"`
+	Mutations json.RawMessage `json:"mutations"`
+}
+
+func (e *journalEntry) decode() ([]mutation, error) {
+	if crc32.ChecksumIEEE(e.Mutations) != e.CRC {
+		return nil, errors.New("checksum mismatch")
+	}
+	var mutations []mutation
+	if err := json.Unmarshal(e.Mutations, &mutations); err != nil {
+		return nil, err
+	}
+	return mutations, nil
+}
+
+// journal is the append-only log of mutations written next to the store
+// file. Each entry is fsynced before the change becomes visible, so a crash
+// loses at most the write that was in flight.
+type journal struct {
+	file *os.File
+	size int64
+	n    int
+}
+
+// ErrStoreLocked is returned by NewFileStore when another process, such as
+// a running server, already has the store open.
+var ErrStoreLocked = errors.New("the store is in use by another process")
+
+// openJournal opens the journal and locks it for as long as it is open, so
+// that only one process at a time replays, appends to and resets it.
+func openJournal(path string) (*journal, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	if err := lockFile(file); err != nil {
+		file.Close()
+		if errors.Is(err, ErrStoreLocked) {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return nil, fmt.Errorf("failed to lock journal: %w", err)
+	}
+	return &journal{file: file}, nil
+}
+
+// replay reads every complete entry and passes it to fn. A torn entry at
+// the tail, left by a crash in the middle of an append, is cut off; damage
+// anywhere else is reported as an error.
+func (j *journal) replay(fn func(entry journalEntry, mutations []mutation) error) error {
+	if _, err := j.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	n := 0
+	size, err := scanJournal(j.file, func(entry journalEntry, mutations []mutation) error {
+		n++
+		return fn(entry, mutations)
+	})
+	if err != nil {
+		return err
+	}
+	j.n = n
+
+	info, err := j.file.Stat()
+	if err != nil {
+		return err
+	}
+	if size < info.Size() {
+		return j.truncate(size)
+	}
+	j.size = size
+	return nil
+}
+
+// scanJournal passes every complete entry in r to fn and returns the
+// length of the valid prefix. Only the last line may be torn.
+func scanJournal(r io.Reader, fn func(entry journalEntry, mutations []mutation) error) (int64, error) {
+	reader := bufio.NewReader(r)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return offset, nil
+		}
+		if err != nil {
+			return offset, fmt.Errorf("failed to read journal: %w", err)
+		}
+
+		var entry journalEntry
+		mutations, decodeErr := decodeJournalLine(line, &entry)
+		if decodeErr != nil {
+			if _, err := reader.Peek(1); errors.Is(err, io.EOF) {
+				return offset, nil
+			}
+			return offset, fmt.Errorf("corrupt journal entry at offset %d: %w", offset, decodeErr)
+		}
+		if err := fn(entry, mutations); err != nil {
+			return offset, err
+		}
+		offset += int64(len(line))
+	}
+}
+
+func decodeJournalLine(line []byte, entry *journalEntry) ([]mutation, error) {
+	if err := json.Unmarshal(bytes.TrimSpace(line), entry); err != nil {
+		return nil, err
+	}
+	return entry.decode()
+}
+
+func (j *journal) append(seq uint64, mutations []mutation) error {
+	payload, err := json.Marshal(mutations)
+	if err != nil {
+		return fmt.Errorf("failed to encode mutations: %w", err)
+	}
+	line, err := json.Marshal(journalEntry{
+		Seq:       seq,
+		Time:      time.Now().UTC(),
+		CRC:       crc32.ChecksumIEEE(payload),
+		Mutations: payload,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode journal entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	if _, err := j.file.WriteAt(line, j.size); err != nil {
+		j.truncate(j.size)
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	if err := j.file.Sync(); err != nil {
+		j.truncate(j.size)
+		return fmt.Errorf("failed to sync journal: %w", err)
+	}
+	j.size += int64(len(line))
+	j.n++
+	return nil
+}
+
+// truncate drops everything after size, discarding a torn or failed write.
+func (j *journal) truncate(size int64) error {
+	if err := j.file.Truncate(size); err != nil {
+		return fmt.Errorf("failed to truncate journal: %w", err)
+	}
+	if err := j.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync journal: %w", err)
+	}
+	j.size = size
+	return nil
+}
+
+// contents returns the raw entries currently in the journal.
+func (j *journal) contents() ([]byte, error) {
+	data := make([]byte, j.size)
+	if _, err := j.file.ReadAt(data, 0); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+	return data, nil
+}
+
+// reset empties the journal once its entries are part of a snapshot.
+func (j *journal) reset() error {
+	if err := j.truncate(0); err != nil {
+		return err
+	}
+	j.n = 0
+	return nil
+}
+
+func (j *journal) close() error {
+	return j.file.Close()
+}
@@ -0,0 +1,28 @@
+//go:build unix
+
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStoreLocked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileStore(path); !errors.Is(err, ErrStoreLocked) {
+		t.Fatalf("second open: got %v, want ErrStoreLocked", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("open after Close: %v", err)
+	}
+	reopened.Close()
+}
@@ -0,0 +1,384 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type migration struct {
+	version int
+	name    string
+	up      string
+	down    string
+}
+
+// sqliteMigrations is the ordered schema history of the SQLite backend.
+// Never edit a migration that has been released; append a new one instead.
+var sqliteMigrations = []migration{
+	{
+		version: 1,
+		name:    "initial schema",
+		up: `
+CREATE TABLE users (
+	id       INTEGER PRIMARY KEY,
+	username TEXT NOT NULL UNIQUE,
+	password TEXT NOT NULL,
+	role     TEXT NOT NULL
+);
+
+CREATE TABLE todo_lists (
+	id                    INTEGER PRIMARY KEY,
+	name                  TEXT NOT NULL,
+	created_at            TEXT NOT NULL,
+	updated_at            TEXT NOT NULL,
+	deleted_at            TEXT,
+	completion_percentage INTEGER NOT NULL DEFAULT 0,
+	user_id               INTEGER NOT NULL
+);
+
+CREATE INDEX idx_todo_lists_user_id ON todo_lists (user_id);
+
+CREATE TABLE todo_items (
+	id           INTEGER NOT NULL,
+	todo_list_id INTEGER NOT NULL REFERENCES todo_lists (id) ON DELETE CASCADE,
+	created_at   TEXT NOT NULL,
+	updated_at   TEXT NOT NULL,
+	deleted_at   TEXT,
+	content      TEXT NOT NULL,
+	is_completed INTEGER NOT NULL DEFAULT 0,
+	user_id      INTEGER NOT NULL,
+	PRIMARY KEY (todo_list_id, id)
+);
+`,
+		down: `
+DROP TABLE todo_items;
+DROP INDEX idx_todo_lists_user_id;
+DROP TABLE todo_lists;
+DROP TABLE users;
+`,
+	},
+	{
+		version: 2,
+		name:    "id sequences",
+		up: `
+CREATE TABLE id_sequences (
+	name    TEXT PRIMARY KEY,
+	last_id INTEGER NOT NULL
+);
+
+INSERT INTO id_sequences (name, last_id) VALUES
+	('todo_lists', (SELECT COALESCE(MAX(id), 0) FROM todo_lists)),
+	('todo_items', (SELECT COALESCE(MAX(id), 0) FROM todo_items));
+`,
+		down: `
+DROP TABLE id_sequences;
+`,
+	},
+	{
+		version: 3,
+		name:    "user id sequence",
+		up: `
+INSERT INTO id_sequences (name, last_id) VALUES
+	('users', (SELECT COALESCE(MAX(id), 0) FROM users));
+`,
+		down: `
+DELETE FROM id_sequences WHERE name = 'users';
+`,
+	},
+	{
+		version: 4,
+		name:    "refresh tokens",
+		up: `
+CREATE TABLE refresh_tokens (
+	token_hash TEXT PRIMARY KEY,
+	family_id  TEXT NOT NULL,
+	user_id    INTEGER NOT NULL,
+	created_at TEXT NOT NULL,
+	expires_at TEXT NOT NULL,
+	used_at    TEXT,
+	revoked_at TEXT
+);
+
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens (family_id);
+`,
+		down: `
+DROP INDEX idx_refresh_tokens_family_id;
+DROP TABLE refresh_tokens;
+`,
+	},
+	{
+		version: 5,
+		name:    "api keys",
+		up: `
+CREATE TABLE api_keys (
+	id         INTEGER PRIMARY KEY,
+	user_id    INTEGER NOT NULL,
+	name       TEXT NOT NULL,
+	prefix     TEXT NOT NULL,
+	key_hash   TEXT NOT NULL UNIQUE,
+	read_only  INTEGER NOT NULL DEFAULT 0,
+	created_at TEXT NOT NULL,
+	expires_at TEXT,
+	revoked_at TEXT
+);
+
+CREATE INDEX idx_api_keys_user_id ON api_keys (user_id);
+
+INSERT INTO id_sequences (name, last_id) VALUES ('api_keys', 0);
+`,
+		down: `
+DELETE FROM id_sequences WHERE name = 'api_keys';
+DROP INDEX idx_api_keys_user_id;
+DROP TABLE api_keys;
+`,
+	},
+	{
+		version: 6,
+		name:    "list members",
+		up: `
+CREATE TABLE list_members (
+	todo_list_id INTEGER NOT NULL REFERENCES todo_lists (id) ON DELETE CASCADE,
+	user_id      INTEGER NOT NULL,
+	role         TEXT NOT NULL,
+	added_at     TEXT NOT NULL,
+	PRIMARY KEY (todo_list_id, user_id)
+);
+
+CREATE INDEX idx_list_members_user_id ON list_members (user_id);
+`,
+		down: `
+DROP INDEX idx_list_members_user_id;
+DROP TABLE list_members;
+`,
+	},
+	{
+		version: 7,
+		name:    "user deactivation",
+		up: `
+ALTER TABLE users ADD COLUMN deactivated_at TEXT;
+`,
+		down: `
+ALTER TABLE users DROP COLUMN deactivated_at;
+`,
+	},
+	{
+		version: 8,
+		name:    "two-factor authentication",
+		up: `
+ALTER TABLE users ADD COLUMN totp_secret TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN totp_enabled INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN totp_last_step INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN recovery_codes TEXT NOT NULL DEFAULT '';
+`,
+		down: `
+ALTER TABLE users DROP COLUMN recovery_codes;
+ALTER TABLE users DROP COLUMN totp_last_step;
+ALTER TABLE users DROP COLUMN totp_enabled;
+ALTER TABLE users DROP COLUMN totp_secret;
+`,
+	},
+	{
+		version: 9,
+		name:    "user email",
+		up: `
+ALTER TABLE users ADD COLUMN email TEXT NOT NULL DEFAULT '';
+CREATE UNIQUE INDEX idx_users_email ON users (email) WHERE email != '';
+`,
+		down: `
+DROP INDEX idx_users_email;
+ALTER TABLE users DROP COLUMN email;
+`,
+	},
+	{
+		version: 10,
+		name:    "sessions",
+		up: `
+CREATE TABLE sessions (
+	id           TEXT PRIMARY KEY,
+	user_id      INTEGER NOT NULL,
+	device       TEXT NOT NULL,
+	ip           TEXT NOT NULL,
+	user_agent   TEXT NOT NULL,
+	created_at   TEXT NOT NULL,
+	last_seen_at TEXT NOT NULL,
+	expires_at   TEXT NOT NULL
+);
+
+CREATE INDEX idx_sessions_user_id ON sessions (user_id);
+`,
+		down: `
+DROP INDEX idx_sessions_user_id;
+DROP TABLE sessions;
+`,
+	},
+	{
+		version: 11,
+		name:    "audit log",
+		up: `
+CREATE TABLE audit_log (
+	id         INTEGER PRIMARY KEY,
+	actor_id   INTEGER NOT NULL,
+	user_id    INTEGER NOT NULL,
+	action     TEXT NOT NULL,
+	status     INTEGER NOT NULL DEFAULT 0,
+	ip         TEXT NOT NULL,
+	created_at TEXT NOT NULL
+);
+
+CREATE INDEX idx_audit_log_actor_id ON audit_log (actor_id);
+CREATE INDEX idx_audit_log_user_id ON audit_log (user_id);
+`,
+		down: `
+DROP INDEX idx_audit_log_user_id;
+DROP INDEX idx_audit_log_actor_id;
+DROP TABLE audit_log;
+`,
+	},
+	{
+		version: 12,
+		name:    "sso identities",
+		up: `
+ALTER TABLE users ADD COLUMN sso_issuer TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN sso_subject TEXT NOT NULL DEFAULT '';
+CREATE UNIQUE INDEX idx_users_sso ON users (sso_issuer, sso_subject) WHERE sso_subject != '';
+`,
+		down: `
+DROP INDEX idx_users_sso;
+ALTER TABLE users DROP COLUMN sso_subject;
+ALTER TABLE users DROP COLUMN sso_issuer;
+`,
+	},
+	// Admins ran the whole server before organizations, so they become
+	// superadmins; admin now runs one organization.
+	{
+		version: 13,
+		name:    "organizations",
+		up: `
+CREATE TABLE organizations (
+	id         INTEGER PRIMARY KEY,
+	name       TEXT NOT NULL UNIQUE,
+	created_at TEXT NOT NULL
+);
+
+INSERT INTO organizations (id, name, created_at)
+VALUES (1, 'default', strftime('%Y-%m-%dT%H:%M:%f000000Z', 'now'));
+
+ALTER TABLE users ADD COLUMN org_id INTEGER NOT NULL DEFAULT 1;
+UPDATE users SET role = 'superadmin' WHERE role = 'admin';
+ALTER TABLE todo_lists ADD COLUMN org_id INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE audit_log ADD COLUMN org_id INTEGER NOT NULL DEFAULT 1;
+CREATE INDEX idx_users_org_id ON users (org_id);
+CREATE INDEX idx_todo_lists_org_id ON todo_lists (org_id);
+CREATE INDEX idx_audit_log_org_id ON audit_log (org_id);
+`,
+		down: `
+DROP INDEX idx_audit_log_org_id;
+DROP INDEX idx_todo_lists_org_id;
+DROP INDEX idx_users_org_id;
+ALTER TABLE audit_log DROP COLUMN org_id;
+ALTER TABLE todo_lists DROP COLUMN org_id;
+ALTER TABLE users DROP COLUMN org_id;
+UPDATE users SET role = 'admin' WHERE role = 'superadmin';
+DROP TABLE organizations;
+`,
+	},
+	{
+		version: 14,
+		name:    "password versions",
+		up: `
+ALTER TABLE users ADD COLUMN password_version INTEGER NOT NULL DEFAULT 0;
+`,
+		down: `
+ALTER TABLE users DROP COLUMN password_version;
+`,
+	},
+}
+
+// LatestSchemaVersion returns the version the SQLite schema is migrated to
+// by default.
+func LatestSchemaVersion() int {
+	return sqliteMigrations[len(sqliteMigrations)-1].version
+}
+
+func ensureMigrationsTable(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+	version    INTEGER PRIMARY KEY,
+	name       TEXT NOT NULL,
+	applied_at TEXT NOT NULL
+)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+// SchemaVersion returns the highest applied migration, or 0 for an empty
+// database.
+func (s *SQLiteStore) SchemaVersion() (int, error) {
+	if err := ensureMigrationsTable(s.db); err != nil {
+		return 0, err
+	}
+	var version int
+	if err := s.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// Migrate brings the schema up to the latest version.
+func (s *SQLiteStore) Migrate() error {
+	return s.MigrateTo(LatestSchemaVersion())
+}
+
+// MigrateTo applies up migrations or reverts down migrations until the
+// schema is at target. Every step runs in its own transaction.
+func (s *SQLiteStore) MigrateTo(target int) error {
+	if target < 0 || target > LatestSchemaVersion() {
+		return fmt.Errorf("unknown schema version %d", target)
+	}
+
+	current, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range sqliteMigrations {
+		if m.version > current && m.version <= target {
+			if err := s.applyMigration(m, m.up, true); err != nil {
+				return err
+			}
+		}
+	}
+	for i := len(sqliteMigrations) - 1; i >= 0; i-- {
+		m := sqliteMigrations[i]
+		if m.version <= current && m.version > target {
+			if err := s.applyMigration(m, m.down, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteStore) applyMigration(m migration, script string, up bool) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(script); err != nil {
+		return fmt.Errorf("migration %d (%s) failed: %w", m.version, m.name, err)
+	}
+	if up {
+		_, err = tx.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+			m.version, m.name, formatTime(time.Now()))
+	} else {
+		_, err = tx.Exec(`DELETE FROM schema_migrations WHERE version = ?`, m.version)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", m.version, err)
+	}
+	return tx.Commit()
+}
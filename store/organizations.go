@@ -0,0 +1,115 @@
+package store
+
+import (
+	"time"
+
+	"github.com/YahyaCengiz/todo-v2/models"
+)
+
+// The roles adoptLegacyRecords converts between. The store knows nothing
+// else about roles.
+const (
+	legacyAdminRole      = "admin"
+	legacySuperadminRole = "superadmin"
+)
+
+// Organizations, like the audit log, are left alone by snapshot restores:
+// they are never removed, so every organization a restored user or list
+// belongs to still exists.
+
+func (s *Store) CreateOrganization(org *models.Organization) error {
+	return s.Tx(func(tx Repository) error { return tx.CreateOrganization(org) })
+}
+
+func (s *Store) GetOrganization(id int) (*models.Organization, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.getOrganization(id)
+}
+
+func (s *Store) GetOrganizations() ([]models.Organization, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]models.Organization{}, s.orgs...), nil
+}
+
+func (s *Store) getOrganization(id int) (*models.Organization, error) {
+	for i := range s.orgs {
+		if s.orgs[i].ID == id {
+			org := s.orgs[i]
+			return &org, nil
+		}
+	}
+	return nil, ErrOrganizationNotFound
+}
+
+// adoptLegacyRecords moves users, lists and audit entries from before
+// organizations existed into the default organization, which it creates if
+// needed. Admins from back then ran the whole server, so they become
+// superadmins. Like observeAll it only goes by the data, so it runs on
+// every load, and it reports whether it changed anything; callers then
+// save the store right away, before the journal can refer to the default
+// organization.
+func (s *Store) adoptLegacyRecords() bool {
+	changed := false
+	if _, err := s.getOrganization(models.DefaultOrganizationID); err != nil {
+		s.orgs = append([]models.Organization{{
+			ID:        models.DefaultOrganizationID,
+			Name:      "default",
+			CreatedAt: time.Now(),
+		}}, s.orgs...)
+		changed = true
+	}
+
+	for i := range s.users {
+		if s.users[i].OrgID == 0 {
+			s.users[i].OrgID = models.DefaultOrganizationID
+			if s.users[i].Role == legacyAdminRole {
+				s.users[i].Role = legacySuperadminRole
+			}
+			changed = true
+		}
+	}
+	listsAdopted := false
+	for i := range s.todoLists {
+		if s.todoLists[i].OrgID == 0 {
+			s.todoLists[i].OrgID = models.DefaultOrganizationID
+			listsAdopted = true
+		}
+	}
+	for i := range s.auditLog {
+		if s.auditLog[i].OrgID == 0 {
+			s.auditLog[i].OrgID = models.DefaultOrganizationID
+			changed = true
+		}
+	}
+	if listsAdopted {
+		s.reindex()
+	}
+	return changed || listsAdopted
+}
+
+// CreateOrganization numbers organizations after the highest ID in use.
+// They are never removed, so no ID counter has to be kept.
+func (tx *storeTx) CreateOrganization(org *models.Organization) error {
+	org.ID = 1
+	for _, other := range tx.s.orgs {
+		if other.Name == org.Name {
+			return ErrOrganizationNameTaken
+		}
+		if other.ID >= org.ID {
+			org.ID = other.ID + 1
+		}
+	}
+	created := *org
+	tx.apply(mutation{Op: opCreateOrg, Organization: &created})
+	return nil
+}
+
+func (tx *storeTx) GetOrganization(id int) (*models.Organization, error) {
+	return tx.s.getOrganization(id)
+}
+
+func (tx *storeTx) GetOrganizations() ([]models.Organization, error) {
+	return append([]models.Organization{}, tx.s.orgs...), nil
+}
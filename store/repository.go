@@ -0,0 +1,182 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"github.com/YahyaCengiz/todo-v2/models"
+)
+
+// TodoRepository persists todo lists and the items nested inside them.
+type TodoRepository interface {
+	CreateTodoList(todoList *models.TodoList) error
+	GetTodoList(id int) (*models.TodoList, error)
+	// GetTodoListsByOrg returns every list of the organization. There is
+	// deliberately no way to read the lists of all organizations at once.
+	GetTodoListsByOrg(orgID int) ([]*models.TodoList, error)
+	GetTodoListsByUser(userID int) ([]*models.TodoList, error)
+	// GetTodoListsByMember returns the lists shared with the user, not the
+	// ones they own.
+	GetTodoListsByMember(userID int) ([]*models.TodoList, error)
+	UpdateTodoList(todoList *models.TodoList) error
+	DeleteTodoList(id int) error
+
+	CreateTodoItem(todoItem *models.TodoItem) error
+	GetTodoItem(listID, itemID int) (*models.TodoItem, error)
+	UpdateTodoItem(listID int, todoItem *models.TodoItem) error
+	DeleteTodoItem(listID, itemID int) error
+
+	// PurgeDeleted permanently removes lists and items soft-deleted before
+	// the given time. Items of a purged list are removed with it.
+	PurgeDeleted(before time.Time) (*PurgeResult, error)
+}
+
+// PurgeResult reports what PurgeDeleted removed.
+type PurgeResult struct {
+	ListIDs []int     `json:"list_ids"`
+	Items   []ItemRef `json:"items"`
+}
+
+type ItemRef struct {
+	ListID int `json:"list_id"`
+	ItemID int `json:"item_id"`
+}
+
+// ErrListNotFound is returned for a todo list ID that does not exist.
+var ErrListNotFound = errors.New("todo list not found")
+
+// ErrItemNotFound is returned when a list has no item with the ID.
+var ErrItemNotFound = errors.New("todo item not found")
+
+// ErrUserNotFound is returned when no account has the ID or username.
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrUsernameTaken is returned by AddUser when another account already uses
+// the username.
+var ErrUsernameTaken = errors.New("username already taken")
+
+// ErrEmailTaken is returned by AddUser and UpdateUser when another account
+// already uses the email address.
+var ErrEmailTaken = errors.New("email address already in use")
+
+// ErrSSOSubjectTaken is returned by AddUser and UpdateUser when another
+// account is already linked to the same SSO identity.
+var ErrSSOSubjectTaken = errors.New("sso identity already linked to another account")
+
+// ErrOrganizationNotFound is returned by GetOrganization for an unknown ID.
+var ErrOrganizationNotFound = errors.New("organization not found")
+
+// ErrOrganizationNameTaken is returned by CreateOrganization when another
+// organization already has the name.
+var ErrOrganizationNameTaken = errors.New("organization name already taken")
+
+// OrganizationRepository persists organizations. They are never changed or
+// removed once created.
+type OrganizationRepository interface {
+	// CreateOrganization stores a new organization and assigns its ID.
+	CreateOrganization(org *models.Organization) error
+	GetOrganization(id int) (*models.Organization, error)
+	GetOrganizations() ([]models.Organization, error)
+}
+
+// UserRepository persists user accounts.
+type UserRepository interface {
+	GetUsers() ([]models.User, error)
+	GetUserByUsername(username string) (*models.User, error)
+	GetUserByID(id int) (*models.User, error)
+	// GetUserByEmail matches the address exactly; callers normalise it.
+	GetUserByEmail(email string) (*models.User, error)
+	// GetUserBySSOSubject returns the account linked to the subject at the
+	// OpenID Connect issuer.
+	GetUserBySSOSubject(issuer, subject string) (*models.User, error)
+
+	// AddUser stores a new account. A zero ID is replaced with the next
+	// free one.
+	AddUser(user *models.User) error
+
+	// UpdateUser overwrites the account with the user's ID and username.
+	// The username itself cannot be changed.
+	UpdateUser(user models.User) error
+	DeleteUser(id int) error
+}
+
+// ErrRefreshTokenNotFound is returned for a refresh token hash that is not
+// stored.
+var ErrRefreshTokenNotFound = errors.New("refresh token not found")
+
+// TokenRepository persists refresh tokens, keyed by the hash of the token.
+type TokenRepository interface {
+	CreateRefreshToken(token *models.RefreshToken) error
+	GetRefreshToken(tokenHash string) (*models.RefreshToken, error)
+	GetRefreshTokensByFamily(familyID string) ([]models.RefreshToken, error)
+	GetRefreshTokensByUser(userID int) ([]models.RefreshToken, error)
+	UpdateRefreshToken(token *models.RefreshToken) error
+
+	// DeleteExpiredRefreshTokens removes tokens that expired before the
+	// given time and returns how many there were.
+	DeleteExpiredRefreshTokens(before time.Time) (int, error)
+}
+
+// ErrSessionNotFound is returned by GetSession for an unknown session ID.
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionRepository persists the device details of login sessions, keyed
+// by the refresh token family ID.
+type SessionRepository interface {
+	CreateSession(session *models.Session) error
+	GetSession(id string) (*models.Session, error)
+	GetSessionsByUser(userID int) ([]models.Session, error)
+	UpdateSession(session *models.Session) error
+
+	// DeleteExpiredSessions removes sessions that expired before the given
+	// time and returns how many there were.
+	DeleteExpiredSessions(before time.Time) (int, error)
+}
+
+// ErrAPIKeyNotFound is returned for an unknown API key ID or hash.
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// APIKeyRepository persists API keys.
+type APIKeyRepository interface {
+	// CreateAPIKey stores a new key and assigns its ID.
+	CreateAPIKey(key *models.APIKey) error
+	GetAPIKey(id int) (*models.APIKey, error)
+	GetAPIKeyByHash(keyHash string) (*models.APIKey, error)
+	GetAPIKeysByUser(userID int) ([]models.APIKey, error)
+	UpdateAPIKey(key *models.APIKey) error
+}
+
+// AuditFilter selects audit entries. Zero fields match everything.
+type AuditFilter struct {
+	OrgID   int
+	ActorID int
+	UserID  int
+}
+
+// AuditRepository persists the audit log. Entries are only ever added, in
+// order, and never changed or removed.
+type AuditRepository interface {
+	// AddAuditEntry stores a new entry and assigns its ID.
+	AddAuditEntry(entry *models.AuditEntry) error
+	GetAuditEntries(filter AuditFilter) ([]models.AuditEntry, error)
+}
+
+// Repository is the storage backend used by the services. Store is the
+// JSON file implementation; other backends only need to satisfy this
+// interface to be swapped in.
+type Repository interface {
+	TodoRepository
+	OrganizationRepository
+	UserRepository
+	TokenRepository
+	SessionRepository
+	APIKeyRepository
+	AuditRepository
+
+	// Tx runs fn so that all changes it makes through tx are committed
+	// together if it returns nil and discarded if it returns an error. fn
+	// must only use tx, not the Repository Tx was called on.
+	Tx(fn func(tx Repository) error) error
+}
+
+var _ Repository = (*Store)(nil)
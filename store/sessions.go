@@ -0,0 +1,97 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/YahyaCengiz/todo-v2/models"
+)
+
+func (s *Store) CreateSession(session *models.Session) error {
+	return s.Tx(func(tx Repository) error { return tx.CreateSession(session) })
+}
+
+func (s *Store) GetSession(id string) (*models.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.getSession(id)
+}
+
+func (s *Store) GetSessionsByUser(userID int) ([]models.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.getSessionsByUser(userID), nil
+}
+
+func (s *Store) UpdateSession(session *models.Session) error {
+	return s.Tx(func(tx Repository) error { return tx.UpdateSession(session) })
+}
+
+func (s *Store) DeleteExpiredSessions(before time.Time) (int, error) {
+	var n int
+	err := s.Tx(func(tx Repository) error {
+		var err error
+		n, err = tx.DeleteExpiredSessions(before)
+		return err
+	})
+	return n, err
+}
+
+func (s *Store) getSession(id string) (*models.Session, error) {
+	if i := s.findSession(id); i >= 0 {
+		session := s.sessions[i]
+		return &session, nil
+	}
+	return nil, ErrSessionNotFound
+}
+
+// getSessionsByUser scans all sessions, like getRefreshTokensByUser; a
+// user only lists their sessions now and then.
+func (s *Store) getSessionsByUser(userID int) []models.Session {
+	sessions := make([]models.Session, 0)
+	for _, session := range s.sessions {
+		if session.UserID == userID {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions
+}
+
+func (tx *storeTx) CreateSession(session *models.Session) error {
+	if tx.s.findSession(session.ID) >= 0 {
+		return fmt.Errorf("session already exists")
+	}
+	created := *session
+	tx.apply(mutation{Op: opCreateSession, Session: &created})
+	return nil
+}
+
+func (tx *storeTx) GetSession(id string) (*models.Session, error) {
+	return tx.s.getSession(id)
+}
+
+func (tx *storeTx) GetSessionsByUser(userID int) ([]models.Session, error) {
+	return tx.s.getSessionsByUser(userID), nil
+}
+
+func (tx *storeTx) UpdateSession(session *models.Session) error {
+	if tx.s.findSession(session.ID) < 0 {
+		return ErrSessionNotFound
+	}
+	updated := *session
+	tx.apply(mutation{Op: opUpdateSession, Session: &updated})
+	return nil
+}
+
+func (tx *storeTx) DeleteExpiredSessions(before time.Time) (int, error) {
+	var expired []string
+	for _, session := range tx.s.sessions {
+		if session.ExpiresAt.Before(before) {
+			expired = append(expired, session.ID)
+		}
+	}
+	for _, id := range expired {
+		tx.apply(mutation{Op: opRemoveSession, Session: &models.Session{ID: id}})
+	}
+	return len(expired), nil
+}
@@ -0,0 +1,631 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/YahyaCengiz/todo-v2/models"
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteStore is a Repository backed by an embedded SQLite database. It uses
+// a pure-Go driver, so it builds without cgo.
+type SQLiteStore struct {
+	db *sql.DB
+	q  querier
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx, so the same methods run
+// inside and outside a transaction.
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+var _ Repository = (*SQLiteStore)(nil)
+
+// NewSQLiteStore opens the database at path and migrates it to the latest
+// schema version.
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	s, err := OpenSQLiteStore(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Migrate(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// OpenSQLiteStore opens the database at path without touching the schema.
+func OpenSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	// SQLite allows a single writer; sharing one connection avoids
+	// "database is locked" errors under concurrent requests.
+	db.SetMaxOpenConns(1)
+
+	for _, pragma := range []string{
+		"PRAGMA foreign_keys = ON",
+		"PRAGMA journal_mode = WAL",
+		"PRAGMA busy_timeout = 5000",
+	} {
+		if _, err := db.Exec(pragma); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to configure database: %w", err)
+		}
+	}
+	return &SQLiteStore{db: db, q: db}, nil
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// Tx runs fn inside a database transaction that is committed when fn
+// returns nil and rolled back otherwise. Nested calls join the outer
+// transaction.
+func (s *SQLiteStore) Tx(fn func(tx Repository) error) error {
+	return s.withTx(func(tx *SQLiteStore) error { return fn(tx) })
+}
+
+func (s *SQLiteStore) withTx(fn func(tx *SQLiteStore) error) error {
+	if _, inTx := s.q.(*sql.Tx); inTx {
+		return fn(s)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(&SQLiteStore{db: s.db, q: tx}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) CreateTodoList(todoList *models.TodoList) error {
+	return s.withTx(func(tx *SQLiteStore) error {
+		id, err := tx.nextID("todo_lists")
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.q.Exec(`INSERT INTO todo_lists
+			(id, org_id, name, created_at, updated_at, deleted_at, completion_percentage, user_id)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			id, todoList.OrgID, todoList.Name, formatTime(todoList.CreatedAt), formatTime(todoList.UpdatedAt),
+			nullTime(todoList.DeletedAt), todoList.CompletionPercentage, todoList.UserID)
+		if err != nil {
+			return fmt.Errorf("failed to create todo list: %w", err)
+		}
+		todoList.ID = id
+		return tx.writeListMembers(todoList)
+	})
+}
+
+func (s *SQLiteStore) GetTodoList(id int) (*models.TodoList, error) {
+	row := s.q.QueryRow(`SELECT id, org_id, name, created_at, updated_at, deleted_at, completion_percentage, user_id
+		FROM todo_lists WHERE id = ?`, id)
+	todoList, err := scanTodoList(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrListNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := s.queryTodoItems(`WHERE todo_list_id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	todoList.TodoItems = items
+
+	members, err := s.queryListMembers(`WHERE todo_list_id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	todoList.Members = members[id]
+	return todoList, nil
+}
+
+func (s *SQLiteStore) GetTodoListsByOrg(orgID int) ([]*models.TodoList, error) {
+	return s.queryTodoLists(`WHERE org_id = ?`, orgID)
+}
+
+func (s *SQLiteStore) GetTodoListsByUser(userID int) ([]*models.TodoList, error) {
+	return s.queryTodoLists(`WHERE user_id = ?`, userID)
+}
+
+// UpdateTodoList writes the list's own fields and members. Items are only
+// changed through the item methods.
+func (s *SQLiteStore) UpdateTodoList(todoList *models.TodoList) error {
+	return s.withTx(func(tx *SQLiteStore) error {
+		res, err := tx.q.Exec(`UPDATE todo_lists
+			SET name = ?, created_at = ?, updated_at = ?, deleted_at = ?, completion_percentage = ?, user_id = ?
+			WHERE id = ?`,
+			todoList.Name, formatTime(todoList.CreatedAt), formatTime(todoList.UpdatedAt),
+			nullTime(todoList.DeletedAt), todoList.CompletionPercentage, todoList.UserID, todoList.ID)
+		if err != nil {
+			return fmt.Errorf("failed to update todo list: %w", err)
+		}
+		if err := expectAffected(res, ErrListNotFound); err != nil {
+			return err
+		}
+		return tx.writeListMembers(todoList)
+	})
+}
+
+func (s *SQLiteStore) DeleteTodoList(id int) error {
+	res, err := s.q.Exec(`UPDATE todo_lists SET deleted_at = ? WHERE id = ?`, formatTime(time.Now()), id)
+	if err != nil {
+		return fmt.Errorf("failed to delete todo list: %w", err)
+	}
+	return expectAffected(res, ErrListNotFound)
+}
+
+func (s *SQLiteStore) CreateTodoItem(todoItem *models.TodoItem) error {
+	return s.withTx(func(tx *SQLiteStore) error {
+		var exists bool
+		if err := tx.q.QueryRow(`SELECT EXISTS (SELECT 1 FROM todo_lists WHERE id = ?)`, todoItem.TodoListID).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrListNotFound
+		}
+
+		id, err := tx.nextID("todo_items")
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.q.Exec(`INSERT INTO todo_items
+			(id, todo_list_id, created_at, updated_at, deleted_at, content, is_completed, user_id)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			id, todoItem.TodoListID, formatTime(todoItem.CreatedAt), formatTime(todoItem.UpdatedAt),
+			nullTime(todoItem.DeletedAt), todoItem.Content, todoItem.IsCompleted, todoItem.UserID)
+		if err != nil {
+			return fmt.Errorf("failed to create todo item: %w", err)
+		}
+		todoItem.ID = id
+		return nil
+	})
+}
+
+func (s *SQLiteStore) GetTodoItem(listID, itemID int) (*models.TodoItem, error) {
+	items, err := s.queryTodoItems(`WHERE todo_list_id = ? AND id = ?`, listID, itemID)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, ErrItemNotFound
+	}
+	return &items[0], nil
+}
+
+func (s *SQLiteStore) UpdateTodoItem(listID int, todoItem *models.TodoItem) error {
+	res, err := s.q.Exec(`UPDATE todo_items
+		SET created_at = ?, updated_at = ?, deleted_at = ?, content = ?, is_completed = ?, user_id = ?
+		WHERE todo_list_id = ? AND id = ?`,
+		formatTime(todoItem.CreatedAt), formatTime(todoItem.UpdatedAt), nullTime(todoItem.DeletedAt),
+		todoItem.Content, todoItem.IsCompleted, todoItem.UserID, listID, todoItem.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update todo item: %w", err)
+	}
+	return expectAffected(res, ErrItemNotFound)
+}
+
+func (s *SQLiteStore) DeleteTodoItem(listID, itemID int) error {
+	res, err := s.q.Exec(`UPDATE todo_items SET deleted_at = ? WHERE todo_list_id = ? AND id = ?`,
+		formatTime(time.Now()), listID, itemID)
+	if err != nil {
+		return fmt.Errorf("failed to delete todo item: %w", err)
+	}
+	return expectAffected(res, ErrItemNotFound)
+}
+
+func (s *SQLiteStore) PurgeDeleted(before time.Time) (*PurgeResult, error) {
+	result := &PurgeResult{ListIDs: []int{}, Items: []ItemRef{}}
+	err := s.withTx(func(tx *SQLiteStore) error {
+		cutoff := formatTime(before)
+
+		rows, err := tx.q.Query(`SELECT id FROM todo_lists WHERE deleted_at IS NOT NULL AND deleted_at < ? ORDER BY id`, cutoff)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			result.ListIDs = append(result.ListIDs, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		rows, err = tx.q.Query(`SELECT todo_list_id, id FROM todo_items
+			WHERE (deleted_at IS NOT NULL AND deleted_at < ?)
+			   OR todo_list_id IN (SELECT id FROM todo_lists WHERE deleted_at IS NOT NULL AND deleted_at < ?)
+			ORDER BY todo_list_id, id`, cutoff, cutoff)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var ref ItemRef
+			if err := rows.Scan(&ref.ListID, &ref.ItemID); err != nil {
+				rows.Close()
+				return err
+			}
+			result.Items = append(result.Items, ref)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if _, err := tx.q.Exec(`DELETE FROM todo_items
+			WHERE (deleted_at IS NOT NULL AND deleted_at < ?)
+			   OR todo_list_id IN (SELECT id FROM todo_lists WHERE deleted_at IS NOT NULL AND deleted_at < ?)`, cutoff, cutoff); err != nil {
+			return fmt.Errorf("failed to purge todo items: %w", err)
+		}
+		if _, err := tx.q.Exec(`DELETE FROM todo_lists WHERE deleted_at IS NOT NULL AND deleted_at < ?`, cutoff); err != nil {
+			return fmt.Errorf("failed to purge todo lists: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *SQLiteStore) GetUsers() ([]models.User, error) {
+	rows, err := s.q.Query(`SELECT id, org_id, username, password, role, email, deactivated_at, totp_secret, totp_enabled, totp_last_step, recovery_codes, sso_issuer, sso_subject, password_version FROM users ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	users := make([]models.User, 0)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+	return users, rows.Err()
+}
+
+func (s *SQLiteStore) GetUserByUsername(username string) (*models.User, error) {
+	row := s.q.QueryRow(`SELECT id, org_id, username, password, role, email, deactivated_at, totp_secret, totp_enabled, totp_last_step, recovery_codes, sso_issuer, sso_subject, password_version FROM users WHERE username = ?`, username)
+	user, err := scanUser(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *SQLiteStore) GetUserByID(id int) (*models.User, error) {
+	row := s.q.QueryRow(`SELECT id, org_id, username, password, role, email, deactivated_at, totp_secret, totp_enabled, totp_last_step, recovery_codes, sso_issuer, sso_subject, password_version FROM users WHERE id = ?`, id)
+	user, err := scanUser(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *SQLiteStore) GetUserByEmail(email string) (*models.User, error) {
+	if email == "" {
+		return nil, ErrUserNotFound
+	}
+	row := s.q.QueryRow(`SELECT id, org_id, username, password, role, email, deactivated_at, totp_secret, totp_enabled, totp_last_step, recovery_codes, sso_issuer, sso_subject, password_version FROM users WHERE email = ?`, email)
+	user, err := scanUser(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *SQLiteStore) GetUserBySSOSubject(issuer, subject string) (*models.User, error) {
+	if subject == "" {
+		return nil, ErrUserNotFound
+	}
+	row := s.q.QueryRow(`SELECT id, org_id, username, password, role, email, deactivated_at, totp_secret, totp_enabled, totp_last_step, recovery_codes, sso_issuer, sso_subject, password_version FROM users WHERE sso_issuer = ? AND sso_subject = ?`, issuer, subject)
+	user, err := scanUser(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *SQLiteStore) AddUser(user *models.User) error {
+	return s.withTx(func(tx *SQLiteStore) error {
+		var taken bool
+		if err := tx.q.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE username = ?)`, user.Username).Scan(&taken); err != nil {
+			return err
+		}
+		if taken {
+			return ErrUsernameTaken
+		}
+		if err := tx.checkEmailFree(user.Email, 0); err != nil {
+			return err
+		}
+		if err := tx.checkSSOSubjectFree(user.SSOIssuer, user.SSOSubject, 0); err != nil {
+			return err
+		}
+
+		id := user.ID
+		if id == 0 {
+			var err error
+			if id, err = tx.nextID("users"); err != nil {
+				return err
+			}
+		} else if _, err := tx.q.Exec(`UPDATE id_sequences SET last_id = MAX(last_id, ?) WHERE name = 'users'`, id); err != nil {
+			return err
+		}
+
+		_, err := tx.q.Exec(`INSERT INTO users (id, org_id, username, password, role, email, deactivated_at, totp_secret, totp_enabled, totp_last_step, recovery_codes, sso_issuer, sso_subject, password_version) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			id, user.OrgID, user.Username, user.Password, user.Role, user.Email, nullTime(user.DeactivatedAt),
+			user.TOTPSecret, user.TOTPEnabled, user.TOTPLastStep, strings.Join(user.RecoveryCodes, " "),
+			user.SSOIssuer, user.SSOSubject, user.PasswordVersion)
+		if err != nil {
+			return fmt.Errorf("failed to add user: %w", err)
+		}
+		user.ID = id
+		return nil
+	})
+}
+
+func (s *SQLiteStore) UpdateUser(user models.User) error {
+	return s.withTx(func(tx *SQLiteStore) error {
+		if err := tx.checkEmailFree(user.Email, user.ID); err != nil {
+			return err
+		}
+		if err := tx.checkSSOSubjectFree(user.SSOIssuer, user.SSOSubject, user.ID); err != nil {
+			return err
+		}
+		res, err := tx.q.Exec(`UPDATE users SET password = ?, role = ?, email = ?, deactivated_at = ?, totp_secret = ?, totp_enabled = ?, totp_last_step = ?, recovery_codes = ?, sso_issuer = ?, sso_subject = ?, password_version = ? WHERE id = ? AND username = ?`,
+			user.Password, user.Role, user.Email, nullTime(user.DeactivatedAt),
+			user.TOTPSecret, user.TOTPEnabled, user.TOTPLastStep, strings.Join(user.RecoveryCodes, " "),
+			user.SSOIssuer, user.SSOSubject, user.PasswordVersion, user.ID, user.Username)
+		if err != nil {
+			return fmt.Errorf("failed to update user: %w", err)
+		}
+		return expectAffected(res, ErrUserNotFound)
+	})
+}
+
+// checkEmailFree returns ErrEmailTaken if a user other than userID has the
+// email address.
+func (s *SQLiteStore) checkEmailFree(email string, userID int) error {
+	if email == "" {
+		return nil
+	}
+	var taken bool
+	if err := s.q.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE email = ? AND id != ?)`, email, userID).Scan(&taken); err != nil {
+		return err
+	}
+	if taken {
+		return ErrEmailTaken
+	}
+	return nil
+}
+
+// checkSSOSubjectFree returns ErrSSOSubjectTaken if a user other than
+// userID is linked to the SSO identity.
+func (s *SQLiteStore) checkSSOSubjectFree(issuer, subject string, userID int) error {
+	if subject == "" {
+		return nil
+	}
+	var taken bool
+	if err := s.q.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE sso_issuer = ? AND sso_subject = ? AND id != ?)`, issuer, subject, userID).Scan(&taken); err != nil {
+		return err
+	}
+	if taken {
+		return ErrSSOSubjectTaken
+	}
+	return nil
+}
+
+func (s *SQLiteStore) DeleteUser(id int) error {
+	res, err := s.q.Exec(`DELETE FROM users WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	return expectAffected(res, ErrUserNotFound)
+}
+
+// queryTodoLists loads the lists matching where together with their items.
+func (s *SQLiteStore) queryTodoLists(where string, args ...any) ([]*models.TodoList, error) {
+	rows, err := s.q.Query(`SELECT id, org_id, name, created_at, updated_at, deleted_at, completion_percentage, user_id
+		FROM todo_lists `+where+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query todo lists: %w", err)
+	}
+	defer rows.Close()
+
+	lists := make([]*models.TodoList, 0)
+	byID := make(map[int]*models.TodoList)
+	for rows.Next() {
+		todoList, err := scanTodoList(rows)
+		if err != nil {
+			return nil, err
+		}
+		todoList.TodoItems = []models.TodoItem{}
+		lists = append(lists, todoList)
+		byID[todoList.ID] = todoList
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	itemWhere := ""
+	if where != "" {
+		itemWhere = `WHERE todo_list_id IN (SELECT id FROM todo_lists ` + where + `)`
+	}
+	items, err := s.queryTodoItems(itemWhere, args...)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if list, ok := byID[item.TodoListID]; ok {
+			list.TodoItems = append(list.TodoItems, item)
+		}
+	}
+
+	memberWhere := ""
+	if where != "" {
+		memberWhere = `WHERE todo_list_id IN (SELECT id FROM todo_lists ` + where + `)`
+	}
+	members, err := s.queryListMembers(memberWhere, args...)
+	if err != nil {
+		return nil, err
+	}
+	for id, list := range byID {
+		list.Members = members[id]
+	}
+	return lists, nil
+}
+
+func (s *SQLiteStore) queryTodoItems(where string, args ...any) ([]models.TodoItem, error) {
+	rows, err := s.q.Query(`SELECT id, todo_list_id, created_at, updated_at, deleted_at, content, is_completed, user_id
+		FROM todo_items `+where+` ORDER BY todo_list_id, id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query todo items: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]models.TodoItem, 0)
+	for rows.Next() {
+		var (
+			item                 models.TodoItem
+			createdAt, updatedAt string
+			deletedAt            sql.NullString
+		)
+		if err := rows.Scan(&item.ID, &item.TodoListID, &createdAt, &updatedAt, &deletedAt,
+			&item.Content, &item.IsCompleted, &item.UserID); err != nil {
+			return nil, err
+		}
+		item.CreatedAt = parseTime(createdAt)
+		item.UpdatedAt = parseTime(updatedAt)
+		item.DeletedAt = parseTime(deletedAt.String)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTodoList(row rowScanner) (*models.TodoList, error) {
+	var (
+		todoList             models.TodoList
+		createdAt, updatedAt string
+		deletedAt            sql.NullString
+	)
+	if err := row.Scan(&todoList.ID, &todoList.OrgID, &todoList.Name, &createdAt, &updatedAt, &deletedAt,
+		&todoList.CompletionPercentage, &todoList.UserID); err != nil {
+		return nil, err
+	}
+	todoList.CreatedAt = parseTime(createdAt)
+	todoList.UpdatedAt = parseTime(updatedAt)
+	todoList.DeletedAt = parseTime(deletedAt.String)
+	return &todoList, nil
+}
+
+// scanUser reads a row of the users columns. Recovery code hashes are
+// stored space-separated.
+func scanUser(row rowScanner) (*models.User, error) {
+	var (
+		user          models.User
+		deactivatedAt sql.NullString
+		recoveryCodes string
+	)
+	if err := row.Scan(&user.ID, &user.OrgID, &user.Username, &user.Password, &user.Role, &user.Email, &deactivatedAt,
+		&user.TOTPSecret, &user.TOTPEnabled, &user.TOTPLastStep, &recoveryCodes,
+		&user.SSOIssuer, &user.SSOSubject, &user.PasswordVersion); err != nil {
+		return nil, err
+	}
+	user.DeactivatedAt = parseTime(deactivatedAt.String)
+	user.RecoveryCodes = strings.Fields(recoveryCodes)
+	return &user, nil
+}
+
+// nextID advances the named counter in id_sequences and returns the new
+// value. The counters never go back, so purging a row does not free its ID.
+func (s *SQLiteStore) nextID(name string) (int, error) {
+	var id int
+	err := s.q.QueryRow(`UPDATE id_sequences SET last_id = last_id + 1 WHERE name = ? RETURNING last_id`, name).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to allocate %s id: %w", name, err)
+	}
+	return id, nil
+}
+
+// expectAffected returns notFound if the statement changed no row.
+func expectAffected(res sql.Result, notFound error) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return notFound
+	}
+	return nil
+}
+
+// timeFormat is fixed width so that SQL can compare timestamps as strings.
+const timeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeFormat)
+}
+
+// nullTime maps the zero time, which the models use for "not set", to NULL.
+func nullTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return formatTime(t)
+}
+
+func parseTime(value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
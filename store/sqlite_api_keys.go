@@ -0,0 +1,91 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/YahyaCengiz/todo-v2/models"
+)
+
+func (s *SQLiteStore) CreateAPIKey(key *models.APIKey) error {
+	return s.withTx(func(tx *SQLiteStore) error {
+		id, err := tx.nextID("api_keys")
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.q.Exec(`INSERT INTO api_keys
+			(id, user_id, name, prefix, key_hash, read_only, created_at, expires_at, revoked_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			id, key.UserID, key.Name, key.Prefix, key.KeyHash, key.ReadOnly,
+			formatTime(key.CreatedAt), nullTime(key.ExpiresAt), nullTime(key.RevokedAt))
+		if err != nil {
+			return fmt.Errorf("failed to create api key: %w", err)
+		}
+		key.ID = id
+		return nil
+	})
+}
+
+func (s *SQLiteStore) GetAPIKey(id int) (*models.APIKey, error) {
+	return s.queryAPIKey(`WHERE id = ?`, id)
+}
+
+func (s *SQLiteStore) GetAPIKeyByHash(keyHash string) (*models.APIKey, error) {
+	return s.queryAPIKey(`WHERE key_hash = ?`, keyHash)
+}
+
+func (s *SQLiteStore) GetAPIKeysByUser(userID int) ([]models.APIKey, error) {
+	return s.queryAPIKeys(`WHERE user_id = ?`, userID)
+}
+
+// UpdateAPIKey changes everything but the key hash.
+func (s *SQLiteStore) UpdateAPIKey(key *models.APIKey) error {
+	res, err := s.q.Exec(`UPDATE api_keys
+		SET user_id = ?, name = ?, prefix = ?, read_only = ?, created_at = ?, expires_at = ?, revoked_at = ?
+		WHERE id = ?`,
+		key.UserID, key.Name, key.Prefix, key.ReadOnly, formatTime(key.CreatedAt),
+		nullTime(key.ExpiresAt), nullTime(key.RevokedAt), key.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update api key: %w", err)
+	}
+	return expectAffected(res, ErrAPIKeyNotFound)
+}
+
+func (s *SQLiteStore) queryAPIKey(where string, args ...any) (*models.APIKey, error) {
+	keys, err := s.queryAPIKeys(where, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ErrAPIKeyNotFound
+	}
+	return &keys[0], nil
+}
+
+func (s *SQLiteStore) queryAPIKeys(where string, args ...any) ([]models.APIKey, error) {
+	rows, err := s.q.Query(`SELECT id, user_id, name, prefix, key_hash, read_only, created_at, expires_at, revoked_at
+		FROM api_keys `+where+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query api keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := make([]models.APIKey, 0)
+	for rows.Next() {
+		var (
+			key                  models.APIKey
+			createdAt            string
+			expiresAt, revokedAt sql.NullString
+		)
+		if err := rows.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.KeyHash, &key.ReadOnly,
+			&createdAt, &expiresAt, &revokedAt); err != nil {
+			return nil, err
+		}
+		key.CreatedAt = parseTime(createdAt)
+		key.ExpiresAt = parseTime(expiresAt.String)
+		key.RevokedAt = parseTime(revokedAt.String)
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
@@ -0,0 +1,68 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/YahyaCengiz/todo-v2/models"
+)
+
+func (s *SQLiteStore) AddAuditEntry(entry *models.AuditEntry) error {
+	res, err := s.q.Exec(`INSERT INTO audit_log (org_id, actor_id, user_id, action, status, ip, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		entry.OrgID, entry.ActorID, entry.UserID, entry.Action, entry.Status, entry.IP, formatTime(entry.CreatedAt))
+	if err != nil {
+		return fmt.Errorf("failed to add audit entry: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	entry.ID = int(id)
+	return nil
+}
+
+func (s *SQLiteStore) GetAuditEntries(filter AuditFilter) ([]models.AuditEntry, error) {
+	var (
+		conds []string
+		args  []any
+	)
+	if filter.OrgID != 0 {
+		conds = append(conds, "org_id = ?")
+		args = append(args, filter.OrgID)
+	}
+	if filter.ActorID != 0 {
+		conds = append(conds, "actor_id = ?")
+		args = append(args, filter.ActorID)
+	}
+	if filter.UserID != 0 {
+		conds = append(conds, "user_id = ?")
+		args = append(args, filter.UserID)
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = "WHERE " + strings.Join(conds, " AND ")
+	}
+
+	rows, err := s.q.Query(`SELECT id, org_id, actor_id, user_id, action, status, ip, created_at
+		FROM audit_log `+where+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]models.AuditEntry, 0)
+	for rows.Next() {
+		var (
+			entry     models.AuditEntry
+			createdAt string
+		)
+		if err := rows.Scan(&entry.ID, &entry.OrgID, &entry.ActorID, &entry.UserID, &entry.Action, &entry.Status,
+			&entry.IP, &createdAt); err != nil {
+			return nil, err
+		}
+		entry.CreatedAt = parseTime(createdAt)
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,52 @@
+package store
+
+import (
+	"fmt"
+
+	"github.com/YahyaCengiz/todo-v2/models"
+)
+
+func (s *SQLiteStore) GetTodoListsByMember(userID int) ([]*models.TodoList, error) {
+	return s.queryTodoLists(`WHERE id IN (SELECT todo_list_id FROM list_members WHERE user_id = ?)`, userID)
+}
+
+// writeListMembers replaces the stored members of the list with its
+// Members.
+func (s *SQLiteStore) writeListMembers(todoList *models.TodoList) error {
+	if _, err := s.q.Exec(`DELETE FROM list_members WHERE todo_list_id = ?`, todoList.ID); err != nil {
+		return fmt.Errorf("failed to update list members: %w", err)
+	}
+	for _, member := range todoList.Members {
+		_, err := s.q.Exec(`INSERT INTO list_members (todo_list_id, user_id, role, added_at) VALUES (?, ?, ?, ?)`,
+			todoList.ID, member.UserID, member.Role, formatTime(member.AddedAt))
+		if err != nil {
+			return fmt.Errorf("failed to update list members: %w", err)
+		}
+	}
+	return nil
+}
+
+// queryListMembers loads the members matching where, grouped by list ID.
+func (s *SQLiteStore) queryListMembers(where string, args ...any) (map[int][]models.ListMember, error) {
+	rows, err := s.q.Query(`SELECT todo_list_id, user_id, role, added_at
+		FROM list_members `+where+` ORDER BY todo_list_id, added_at, user_id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query list members: %w", err)
+	}
+	defer rows.Close()
+
+	members := make(map[int][]models.ListMember)
+	for rows.Next() {
+		var (
+			listID  int
+			member  models.ListMember
+			addedAt string
+		)
+		if err := rows.Scan(&listID, &member.UserID, &member.Role, &addedAt); err != nil {
+			return nil, err
+		}
+		member.AddedAt = parseTime(addedAt)
+		members[listID] = append(members[listID], member)
+	}
+	return members, rows.Err()
+}
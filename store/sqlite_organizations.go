@@ -0,0 +1,72 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/YahyaCengiz/todo-v2/models"
+)
+
+func (s *SQLiteStore) CreateOrganization(org *models.Organization) error {
+	return s.withTx(func(tx *SQLiteStore) error {
+		var taken bool
+		if err := tx.q.QueryRow(`SELECT EXISTS (SELECT 1 FROM organizations WHERE name = ?)`, org.Name).Scan(&taken); err != nil {
+			return err
+		}
+		if taken {
+			return ErrOrganizationNameTaken
+		}
+
+		res, err := tx.q.Exec(`INSERT INTO organizations (name, created_at) VALUES (?, ?)`,
+			org.Name, formatTime(org.CreatedAt))
+		if err != nil {
+			return fmt.Errorf("failed to create organization: %w", err)
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		org.ID = int(id)
+		return nil
+	})
+}
+
+func (s *SQLiteStore) GetOrganization(id int) (*models.Organization, error) {
+	var (
+		org       models.Organization
+		createdAt string
+	)
+	err := s.q.QueryRow(`SELECT id, name, created_at FROM organizations WHERE id = ?`, id).
+		Scan(&org.ID, &org.Name, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrOrganizationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	org.CreatedAt = parseTime(createdAt)
+	return &org, nil
+}
+
+func (s *SQLiteStore) GetOrganizations() ([]models.Organization, error) {
+	rows, err := s.q.Query(`SELECT id, name, created_at FROM organizations ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query organizations: %w", err)
+	}
+	defer rows.Close()
+
+	orgs := make([]models.Organization, 0)
+	for rows.Next() {
+		var (
+			org       models.Organization
+			createdAt string
+		)
+		if err := rows.Scan(&org.ID, &org.Name, &createdAt); err != nil {
+			return nil, err
+		}
+		org.CreatedAt = parseTime(createdAt)
+		orgs = append(orgs, org)
+	}
+	return orgs, rows.Err()
+}
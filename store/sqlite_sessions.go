@@ -0,0 +1,89 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/YahyaCengiz/todo-v2/models"
+)
+
+func (s *SQLiteStore) CreateSession(session *models.Session) error {
+	_, err := s.q.Exec(`INSERT INTO sessions
+		(id, user_id, device, ip, user_agent, created_at, last_seen_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		session.ID, session.UserID, session.Device, session.IP, session.UserAgent,
+		formatTime(session.CreatedAt), formatTime(session.LastSeenAt), formatTime(session.ExpiresAt))
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) GetSession(id string) (*models.Session, error) {
+	sessions, err := s.querySessions(`WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, ErrSessionNotFound
+	}
+	return &sessions[0], nil
+}
+
+func (s *SQLiteStore) GetSessionsByUser(userID int) ([]models.Session, error) {
+	return s.querySessions(`WHERE user_id = ?`, userID)
+}
+
+func (s *SQLiteStore) UpdateSession(session *models.Session) error {
+	res, err := s.q.Exec(`UPDATE sessions
+		SET user_id = ?, device = ?, ip = ?, user_agent = ?, created_at = ?, last_seen_at = ?, expires_at = ?
+		WHERE id = ?`,
+		session.UserID, session.Device, session.IP, session.UserAgent, formatTime(session.CreatedAt),
+		formatTime(session.LastSeenAt), formatTime(session.ExpiresAt), session.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteStore) DeleteExpiredSessions(before time.Time) (int, error) {
+	res, err := s.q.Exec(`DELETE FROM sessions WHERE expires_at < ?`, formatTime(before))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired sessions: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (s *SQLiteStore) querySessions(where string, args ...any) ([]models.Session, error) {
+	rows, err := s.q.Query(`SELECT id, user_id, device, ip, user_agent, created_at, last_seen_at, expires_at
+		FROM sessions `+where+` ORDER BY created_at`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := make([]models.Session, 0)
+	for rows.Next() {
+		var (
+			session                          models.Session
+			createdAt, lastSeenAt, expiresAt string
+		)
+		if err := rows.Scan(&session.ID, &session.UserID, &session.Device, &session.IP, &session.UserAgent,
+			&createdAt, &lastSeenAt, &expiresAt); err != nil {
+			return nil, err
+		}
+		session.CreatedAt = parseTime(createdAt)
+		session.LastSeenAt = parseTime(lastSeenAt)
+		session.ExpiresAt = parseTime(expiresAt)
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
@@ -0,0 +1,89 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/YahyaCengiz/todo-v2/models"
+)
+
+func (s *SQLiteStore) CreateRefreshToken(token *models.RefreshToken) error {
+	_, err := s.q.Exec(`INSERT INTO refresh_tokens
+		(token_hash, family_id, user_id, created_at, expires_at, used_at, revoked_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		token.TokenHash, token.FamilyID, token.UserID, formatTime(token.CreatedAt),
+		formatTime(token.ExpiresAt), nullTime(token.UsedAt), nullTime(token.RevokedAt))
+	if err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) GetRefreshToken(tokenHash string) (*models.RefreshToken, error) {
+	tokens, err := s.queryRefreshTokens(`WHERE token_hash = ?`, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, ErrRefreshTokenNotFound
+	}
+	return &tokens[0], nil
+}
+
+func (s *SQLiteStore) GetRefreshTokensByFamily(familyID string) ([]models.RefreshToken, error) {
+	return s.queryRefreshTokens(`WHERE family_id = ?`, familyID)
+}
+
+func (s *SQLiteStore) GetRefreshTokensByUser(userID int) ([]models.RefreshToken, error) {
+	return s.queryRefreshTokens(`WHERE user_id = ?`, userID)
+}
+
+func (s *SQLiteStore) UpdateRefreshToken(token *models.RefreshToken) error {
+	res, err := s.q.Exec(`UPDATE refresh_tokens
+		SET family_id = ?, user_id = ?, created_at = ?, expires_at = ?, used_at = ?, revoked_at = ?
+		WHERE token_hash = ?`,
+		token.FamilyID, token.UserID, formatTime(token.CreatedAt), formatTime(token.ExpiresAt),
+		nullTime(token.UsedAt), nullTime(token.RevokedAt), token.TokenHash)
+	if err != nil {
+		return fmt.Errorf("failed to update refresh token: %w", err)
+	}
+	return expectAffected(res, ErrRefreshTokenNotFound)
+}
+
+func (s *SQLiteStore) DeleteExpiredRefreshTokens(before time.Time) (int, error) {
+	res, err := s.q.Exec(`DELETE FROM refresh_tokens WHERE expires_at < ?`, formatTime(before))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired refresh tokens: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (s *SQLiteStore) queryRefreshTokens(where string, args ...any) ([]models.RefreshToken, error) {
+	rows, err := s.q.Query(`SELECT token_hash, family_id, user_id, created_at, expires_at, used_at, revoked_at
+		FROM refresh_tokens `+where+` ORDER BY created_at`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query refresh tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := make([]models.RefreshToken, 0)
+	for rows.Next() {
+		var (
+			token                models.RefreshToken
+			createdAt, expiresAt string
+			usedAt, revokedAt    sql.NullString
+		)
+		if err := rows.Scan(&token.TokenHash, &token.FamilyID, &token.UserID,
+			&createdAt, &expiresAt, &usedAt, &revokedAt); err != nil {
+			return nil, err
+		}
+		token.CreatedAt = parseTime(createdAt)
+		token.ExpiresAt = parseTime(expiresAt)
+		token.UsedAt = parseTime(usedAt.String)
+		token.RevokedAt = parseTime(revokedAt.String)
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
@@ -4,200 +4,592 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/YahyaCengiz/todo-v2/models"
 )
 
+// compactAfter is the number of journal entries after which the journal is
+// folded into a fresh snapshot of the store file.
+const compactAfter = 100
+
 type Store struct {
 	mu        sync.RWMutex
 	todoLists []models.TodoList
 	users     []models.User
+	tokens    []models.RefreshToken
+	sessions  []models.Session
+	apiKeys   []models.APIKey
+	auditLog  []models.AuditEntry
+	orgs      []models.Organization
 	filePath  string
+	journal   *journal
+	seq       uint64
+	idx       index
+	ids       ids
+
+	backupDir  string
+	backupKeep int
+}
+
+// snapshot is the on-disk layout of the store file. Seq is the last journal
+// entry folded into it; older entries are skipped on replay. The Last*ID
+// fields are the highest IDs ever handed out, including purged ones.
+type snapshot struct {
+	Seq        uint64            `json:"seq,omitempty"`
+	LastListID int               `json:"last_list_id,omitempty"`
+	LastItemID int               `json:"last_item_id,omitempty"`
+	LastUserID int               `json:"last_user_id,omitempty"`
+	LastKeyID  int               `json:"last_api_key_id,omitempty"`
+	TodoLists  []models.TodoList `json:"todo_lists"`
+	Users      []models.User     `json:"users"`
+
+	RefreshTokens []models.RefreshToken `json:"refresh_tokens,omitempty"`
+	Sessions      []models.Session      `json:"sessions,omitempty"`
+	APIKeys       []models.APIKey       `json:"api_keys,omitempty"`
+	AuditLog      []models.AuditEntry   `json:"audit_log,omitempty"`
+	Organizations []models.Organization `json:"organizations,omitempty"`
 }
 
+const defaultFilePath = "data/store.json"
+
 func NewStore() *Store {
+	s, err := NewFileStore(defaultFilePath)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to load store.json: %v", err))
+	}
+	return s
+}
+
+// NewFileStore opens a store backed by the JSON file at filePath and the
+// journal next to it. A missing file is treated as an empty store. The
+// store stays locked until Close, and opening it while another process has
+// it open fails with ErrStoreLocked.
+func NewFileStore(filePath string, opts ...Option) (*Store, error) {
 	s := &Store{
 		todoLists: make([]models.TodoList, 0),
 		users:     make([]models.User, 0),
-		filePath:  "data/store.json",
+		filePath:  filePath,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	j, err := openJournal(filePath + ".journal")
+	if err != nil {
+		return nil, err
 	}
 	if err := s.loadFromFile(); err != nil {
-		panic(fmt.Sprintf("Failed to load store.json: %v", err))
+		j.close()
+		return nil, err
+	}
+	s.reindex()
+
+	s.journal = j
+	if err := s.replayJournal(); err != nil {
+		j.close()
+		return nil, err
+	}
+	renumbered := s.renumberDuplicateItems()
+	if s.adoptLegacyRecords() || renumbered {
+		if err := s.compact(); err != nil {
+			j.close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// NewMemoryStore returns a store that keeps everything in memory and never
+// touches the disk. It is meant for tests and throwaway environments.
+func NewMemoryStore() *Store {
+	s := &Store{
+		todoLists: make([]models.TodoList, 0),
+		users:     make([]models.User, 0),
 	}
+	s.reindex()
+	s.adoptLegacyRecords()
 	return s
 }
 
-func (s *Store) CreateTodoList(todoList *models.TodoList) error {
+// Close folds the journal into the store file and releases it.
+func (s *Store) Close() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if len(s.todoLists) == 0 {
-		todoList.ID = 1
-	} else {
-		todoList.ID = s.todoLists[len(s.todoLists)-1].ID + 1
+	if s.journal == nil {
+		return nil
 	}
+	err := s.compact()
+	if cerr := s.journal.close(); err == nil {
+		err = cerr
+	}
+	s.journal = nil
+	return err
+}
 
-	s.todoLists = append(s.todoLists, *todoList)
-	return s.saveToFile()
+func (s *Store) CreateTodoList(todoList *models.TodoList) error {
+	return s.Tx(func(tx Repository) error { return tx.CreateTodoList(todoList) })
 }
 
 func (s *Store) GetTodoList(id int) (*models.TodoList, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
+	return s.getTodoList(id)
+}
 
-	for i := range s.todoLists {
-		if s.todoLists[i].ID == id {
-			return &s.todoLists[i], nil
-		}
-	}
-	return nil, fmt.Errorf("todo list not found")
+func (s *Store) GetTodoListsByOrg(orgID int) ([]*models.TodoList, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.getTodoListsByOrg(orgID), nil
 }
 
-func (s *Store) GetAllTodoLists() ([]*models.TodoList, error) {
+func (s *Store) GetTodoListsByUser(userID int) ([]*models.TodoList, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
+	return s.getTodoListsByUser(userID), nil
+}
 
-	lists := make([]*models.TodoList, len(s.todoLists))
-	for i := range s.todoLists {
-		lists[i] = &s.todoLists[i]
-	}
-	return lists, nil
+func (s *Store) GetTodoListsByMember(userID int) ([]*models.TodoList, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.getTodoListsByMember(userID), nil
 }
 
+// UpdateTodoList writes the list's own fields. Items are only changed
+// through the item methods.
 func (s *Store) UpdateTodoList(todoList *models.TodoList) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	return s.Tx(func(tx Repository) error { return tx.UpdateTodoList(todoList) })
+}
 
-	for i := range s.todoLists {
-		if s.todoLists[i].ID == todoList.ID {
-			s.todoLists[i] = *todoList
-			return s.saveToFile()
-		}
+func (s *Store) DeleteTodoList(id int) error {
+	return s.Tx(func(tx Repository) error { return tx.DeleteTodoList(id) })
+}
+
+func (s *Store) CreateTodoItem(todoItem *models.TodoItem) error {
+	return s.Tx(func(tx Repository) error { return tx.CreateTodoItem(todoItem) })
+}
+
+func (s *Store) GetTodoItem(listID, itemID int) (*models.TodoItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.getTodoItem(listID, itemID)
+}
+
+func (s *Store) UpdateTodoItem(listID int, todoItem *models.TodoItem) error {
+	return s.Tx(func(tx Repository) error { return tx.UpdateTodoItem(listID, todoItem) })
+}
+
+func (s *Store) DeleteTodoItem(listID, itemID int) error {
+	return s.Tx(func(tx Repository) error { return tx.DeleteTodoItem(listID, itemID) })
+}
+
+func (s *Store) PurgeDeleted(before time.Time) (*PurgeResult, error) {
+	var result *PurgeResult
+	err := s.Tx(func(tx Repository) error {
+		var err error
+		result, err = tx.PurgeDeleted(before)
+		return err
+	})
+	return result, err
+}
+
+func (s *Store) GetUsers() ([]models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return copyUsers(s.users), nil
+}
+
+func (s *Store) GetUserByUsername(username string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.getUserByUsername(username)
+}
+
+func (s *Store) GetUserByID(id int) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.getUserByID(id)
+}
+
+func (s *Store) GetUserByEmail(email string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.getUserByEmail(email)
+}
+
+func (s *Store) GetUserBySSOSubject(issuer, subject string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.getUserBySSOSubject(issuer, subject)
+}
+
+func (s *Store) AddUser(user *models.User) error {
+	return s.Tx(func(tx Repository) error { return tx.AddUser(user) })
+}
+
+func (s *Store) UpdateUser(user models.User) error {
+	return s.Tx(func(tx Repository) error { return tx.UpdateUser(user) })
+}
+
+func (s *Store) DeleteUser(id int) error {
+	return s.Tx(func(tx Repository) error { return tx.DeleteUser(id) })
+}
+
+// The unexported getters below expect the caller to hold the lock; they are
+// shared by the public methods and by transactions.
+
+func (s *Store) getTodoList(id int) (*models.TodoList, error) {
+	if i := s.findList(id); i >= 0 {
+		return copyList(&s.todoLists[i]), nil
 	}
-	return fmt.Errorf("todo list not found")
+	return nil, ErrListNotFound
 }
 
-func (s *Store) DeleteTodoList(id int) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+func (s *Store) getTodoListsByOrg(orgID int) []*models.TodoList {
+	ids := s.idx.orgLists[orgID]
+	lists := make([]*models.TodoList, 0, len(ids))
+	for _, id := range ids {
+		lists = append(lists, copyList(&s.todoLists[s.idx.lists[id]]))
+	}
+	return lists
+}
 
-	for i := range s.todoLists {
-		if s.todoLists[i].ID == id {
-			s.todoLists[i].DeletedAt = time.Now()
-			return s.saveToFile()
-		}
+func (s *Store) getTodoListsByUser(userID int) []*models.TodoList {
+	owned := s.idx.owners[userID]
+	lists := make([]*models.TodoList, 0, len(owned))
+	for _, id := range owned {
+		lists = append(lists, copyList(&s.todoLists[s.idx.lists[id]]))
 	}
-	return fmt.Errorf("todo list not found")
+	return lists
 }
 
-func (s *Store) CreateTodoItem(todoItem *models.TodoItem) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+func (s *Store) getTodoListsByMember(userID int) []*models.TodoList {
+	shared := s.idx.shared[userID]
+	lists := make([]*models.TodoList, 0, len(shared))
+	for _, id := range shared {
+		lists = append(lists, copyList(&s.todoLists[s.idx.lists[id]]))
+	}
+	return lists
+}
 
-	for i := range s.todoLists {
-		if s.todoLists[i].ID == todoItem.TodoListID {
-			if len(s.todoLists[i].TodoItems) == 0 {
-				todoItem.ID = 1
-			} else {
-				todoItem.ID = s.todoLists[i].TodoItems[len(s.todoLists[i].TodoItems)-1].ID + 1
-			}
-			s.todoLists[i].TodoItems = append(s.todoLists[i].TodoItems, *todoItem)
-			return s.saveToFile()
-		}
+func (s *Store) getTodoItem(listID, itemID int) (*models.TodoItem, error) {
+	if i, j := s.findItem(listID, itemID); j >= 0 {
+		return copyItem(&s.todoLists[i].TodoItems[j]), nil
 	}
-	return fmt.Errorf("todo list not found")
+	return nil, ErrItemNotFound
 }
 
-func (s *Store) GetTodoItem(listID, itemID int) (*models.TodoItem, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+func (s *Store) getUserByUsername(username string) (*models.User, error) {
+	user, ok := s.findUser(username)
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return copyUser(user), nil
+}
 
-	for i := range s.todoLists {
-		if s.todoLists[i].ID == listID {
-			for j := range s.todoLists[i].TodoItems {
-				if s.todoLists[i].TodoItems[j].ID == itemID {
-					return &s.todoLists[i].TodoItems[j], nil
-				}
-			}
-		}
+func (s *Store) getUserByID(id int) (*models.User, error) {
+	user, ok := s.findUserByID(id)
+	if !ok {
+		return nil, ErrUserNotFound
 	}
-	return nil, fmt.Errorf("todo item not found")
+	return copyUser(user), nil
 }
 
-func (s *Store) UpdateTodoItem(listID int, todoItem *models.TodoItem) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+func (s *Store) getUserByEmail(email string) (*models.User, error) {
+	user, ok := s.findUserByEmail(email)
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return copyUser(user), nil
+}
+
+func (s *Store) getUserBySSOSubject(issuer, subject string) (*models.User, error) {
+	user, ok := s.findUserBySSOSubject(issuer, subject)
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return copyUser(user), nil
+}
 
-	for i := range s.todoLists {
-		if s.todoLists[i].ID == listID {
-			for j := range s.todoLists[i].TodoItems {
-				if s.todoLists[i].TodoItems[j].ID == todoItem.ID {
-					s.todoLists[i].TodoItems[j] = *todoItem
-					return s.saveToFile()
-				}
+func (s *Store) apply(m mutation) {
+	switch m.Op {
+	case opCreateList:
+		todoList := *m.List
+		if todoList.TodoItems == nil {
+			todoList.TodoItems = []models.TodoItem{}
+		}
+		if m.at > 0 {
+			s.todoLists = slices.Insert(s.todoLists, m.at-1, todoList)
+			s.reindex()
+			return
+		}
+		s.ids.observeList(todoList.ID)
+		s.todoLists = append(s.todoLists, todoList)
+		s.indexList(len(s.todoLists) - 1)
+		for j := range todoList.TodoItems {
+			s.indexItem(len(s.todoLists)-1, j)
+		}
+	case opUpdateList:
+		if i := s.findList(m.List.ID); i >= 0 {
+			s.moveOwner(m.List.ID, s.todoLists[i].UserID, m.List.UserID)
+			reshared := !slices.Equal(s.todoLists[i].Members, m.List.Members)
+			items := s.todoLists[i].TodoItems
+			s.todoLists[i] = *m.List
+			s.todoLists[i].TodoItems = items
+			if reshared {
+				s.reindex()
+			}
+		}
+	case opRemoveList:
+		if i := s.findList(m.List.ID); i >= 0 {
+			s.todoLists = slices.Delete(s.todoLists, i, i+1)
+			s.reindex()
+		}
+	case opCreateItem:
+		if i := s.findList(m.Item.TodoListID); i >= 0 {
+			if m.at > 0 {
+				s.todoLists[i].TodoItems = slices.Insert(s.todoLists[i].TodoItems, m.at-1, *m.Item)
+				s.reindex()
+				return
 			}
+			s.ids.observeItem(m.Item.ID)
+			s.todoLists[i].TodoItems = append(s.todoLists[i].TodoItems, *m.Item)
+			s.indexItem(i, len(s.todoLists[i].TodoItems)-1)
+		}
+	case opUpdateItem:
+		if i, j := s.findItem(m.Item.TodoListID, m.Item.ID); j >= 0 {
+			s.todoLists[i].TodoItems[j] = *m.Item
+		}
+	case opRemoveItem:
+		if i, j := s.findItem(m.Item.TodoListID, m.Item.ID); j >= 0 {
+			s.todoLists[i].TodoItems = slices.Delete(s.todoLists[i].TodoItems, j, j+1)
+			s.reindex()
+		}
+	case opAddUser:
+		if m.at > 0 {
+			s.users = slices.Insert(s.users, m.at-1, *m.User)
+			s.reindex()
+			return
+		}
+		s.ids.observeUser(m.User.ID)
+		s.users = append(s.users, *m.User)
+		s.indexUser(len(s.users) - 1)
+	case opUpdateUser:
+		if i := s.findUserPos(m.User.ID, m.User.Username); i >= 0 {
+			reindex := reindexesUser(&s.users[i], m.User)
+			s.users[i] = *m.User
+			if reindex {
+				s.reindex()
+			}
+		}
+	case opRemoveUser:
+		if i := s.findUserPos(m.User.ID, m.User.Username); i >= 0 {
+			s.users = slices.Delete(s.users, i, i+1)
+			s.reindex()
+		}
+	case opCreateToken:
+		if m.at > 0 {
+			s.tokens = slices.Insert(s.tokens, m.at-1, *m.Token)
+			s.reindex()
+			return
+		}
+		s.tokens = append(s.tokens, *m.Token)
+		s.indexToken(len(s.tokens) - 1)
+	case opUpdateToken:
+		if i := s.findToken(m.Token.TokenHash); i >= 0 {
+			s.tokens[i] = *m.Token
+		}
+	case opRemoveToken:
+		if i := s.findToken(m.Token.TokenHash); i >= 0 {
+			s.tokens = slices.Delete(s.tokens, i, i+1)
+			s.reindex()
+		}
+	case opCreateSession:
+		if m.at > 0 {
+			s.sessions = slices.Insert(s.sessions, m.at-1, *m.Session)
+			s.reindex()
+			return
+		}
+		s.sessions = append(s.sessions, *m.Session)
+		s.idx.sessions[m.Session.ID] = len(s.sessions) - 1
+	case opUpdateSession:
+		if i := s.findSession(m.Session.ID); i >= 0 {
+			s.sessions[i] = *m.Session
+		}
+	case opRemoveSession:
+		if i := s.findSession(m.Session.ID); i >= 0 {
+			s.sessions = slices.Delete(s.sessions, i, i+1)
+			s.reindex()
+		}
+	case opCreateAPIKey:
+		if m.at > 0 {
+			s.apiKeys = slices.Insert(s.apiKeys, m.at-1, *m.APIKey)
+			s.reindex()
+			return
+		}
+		s.ids.observeKey(m.APIKey.ID)
+		s.apiKeys = append(s.apiKeys, *m.APIKey)
+		s.indexAPIKey(len(s.apiKeys) - 1)
+	case opUpdateAPIKey:
+		if i := s.findAPIKey(m.APIKey.ID); i >= 0 {
+			s.apiKeys[i] = *m.APIKey
+		}
+	case opRemoveAPIKey:
+		if i := s.findAPIKey(m.APIKey.ID); i >= 0 {
+			s.apiKeys = slices.Delete(s.apiKeys, i, i+1)
+			s.reindex()
+		}
+	case opAddAuditEntry:
+		s.auditLog = append(s.auditLog, *m.AuditEntry)
+	case opRemoveAuditEntry:
+		// Only undo removes entries, and always the newest one.
+		if n := len(s.auditLog); n > 0 && s.auditLog[n-1].ID == m.AuditEntry.ID {
+			s.auditLog = s.auditLog[:n-1]
+		}
+	case opCreateOrg:
+		s.orgs = append(s.orgs, *m.Organization)
+	case opRemoveOrg:
+		// Like audit entries, organizations are only removed by undo.
+		if n := len(s.orgs); n > 0 && s.orgs[n-1].ID == m.Organization.ID {
+			s.orgs = s.orgs[:n-1]
 		}
 	}
-	return fmt.Errorf("todo item not found")
 }
 
-func (s *Store) DeleteTodoItem(listID, itemID int) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	for i := range s.todoLists {
-		if s.todoLists[i].ID == listID {
-			for j := range s.todoLists[i].TodoItems {
-				if s.todoLists[i].TodoItems[j].ID == itemID {
-					s.todoLists[i].TodoItems[j].DeletedAt = time.Now()
-					return s.saveToFile()
-				}
-			}
+// inverse returns the mutation that undoes m. It must be called before m
+// is applied.
+func (s *Store) inverse(m mutation) mutation {
+	switch m.Op {
+	case opCreateList:
+		return mutation{Op: opRemoveList, List: &models.TodoList{ID: m.List.ID}}
+	case opUpdateList:
+		if i := s.findList(m.List.ID); i >= 0 {
+			return mutation{Op: opUpdateList, List: listFields(&s.todoLists[i])}
+		}
+	case opRemoveList:
+		if i := s.findList(m.List.ID); i >= 0 {
+			return mutation{Op: opCreateList, List: copyList(&s.todoLists[i]), at: i + 1}
+		}
+	case opCreateItem:
+		return mutation{Op: opRemoveItem, Item: &models.TodoItem{TodoListID: m.Item.TodoListID, ID: m.Item.ID}}
+	case opUpdateItem:
+		if i, j := s.findItem(m.Item.TodoListID, m.Item.ID); j >= 0 {
+			return mutation{Op: opUpdateItem, Item: copyItem(&s.todoLists[i].TodoItems[j])}
+		}
+	case opRemoveItem:
+		if i, j := s.findItem(m.Item.TodoListID, m.Item.ID); j >= 0 {
+			return mutation{Op: opCreateItem, Item: copyItem(&s.todoLists[i].TodoItems[j]), at: j + 1}
+		}
+	case opAddUser:
+		return mutation{Op: opRemoveUser, User: m.User}
+	case opUpdateUser:
+		if i := s.findUserPos(m.User.ID, m.User.Username); i >= 0 {
+			return mutation{Op: opUpdateUser, User: copyUser(&s.users[i])}
+		}
+	case opRemoveUser:
+		if i := s.findUserPos(m.User.ID, m.User.Username); i >= 0 {
+			return mutation{Op: opAddUser, User: copyUser(&s.users[i]), at: i + 1}
 		}
+	case opCreateToken:
+		return mutation{Op: opRemoveToken, Token: &models.RefreshToken{TokenHash: m.Token.TokenHash}}
+	case opUpdateToken:
+		if i := s.findToken(m.Token.TokenHash); i >= 0 {
+			token := s.tokens[i]
+			return mutation{Op: opUpdateToken, Token: &token}
+		}
+	case opRemoveToken:
+		if i := s.findToken(m.Token.TokenHash); i >= 0 {
+			token := s.tokens[i]
+			return mutation{Op: opCreateToken, Token: &token, at: i + 1}
+		}
+	case opCreateSession:
+		return mutation{Op: opRemoveSession, Session: &models.Session{ID: m.Session.ID}}
+	case opUpdateSession:
+		if i := s.findSession(m.Session.ID); i >= 0 {
+			session := s.sessions[i]
+			return mutation{Op: opUpdateSession, Session: &session}
+		}
+	case opRemoveSession:
+		if i := s.findSession(m.Session.ID); i >= 0 {
+			session := s.sessions[i]
+			return mutation{Op: opCreateSession, Session: &session, at: i + 1}
+		}
+	case opCreateAPIKey:
+		return mutation{Op: opRemoveAPIKey, APIKey: &models.APIKey{ID: m.APIKey.ID}}
+	case opUpdateAPIKey:
+		if i := s.findAPIKey(m.APIKey.ID); i >= 0 {
+			key := s.apiKeys[i]
+			return mutation{Op: opUpdateAPIKey, APIKey: &key}
+		}
+	case opRemoveAPIKey:
+		if i := s.findAPIKey(m.APIKey.ID); i >= 0 {
+			key := s.apiKeys[i]
+			return mutation{Op: opCreateAPIKey, APIKey: &key, at: i + 1}
+		}
+	case opAddAuditEntry:
+		return mutation{Op: opRemoveAuditEntry, AuditEntry: &models.AuditEntry{ID: m.AuditEntry.ID}}
+	case opCreateOrg:
+		return mutation{Op: opRemoveOrg, Organization: &models.Organization{ID: m.Organization.ID}}
 	}
-	return fmt.Errorf("todo item not found")
+	// m targets a record that does not exist, so applying it is a no-op.
+	return mutation{}
 }
 
-func (s *Store) GetUsers() []models.User {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	return s.users
+func (s *Store) replayJournal() error {
+	replayed := false
+	err := s.journal.replay(func(entry journalEntry, mutations []mutation) error {
+		if entry.Seq <= s.seq {
+			return nil
+		}
+		for _, m := range mutations {
+			s.apply(m)
+		}
+		s.seq = entry.Seq
+		replayed = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if replayed {
+		return s.compact()
+	}
+	return nil
 }
 
-func (s *Store) AddUser(user models.User) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.users = append(s.users, user)
-	return s.saveToFile()
+// compact writes a snapshot that includes every journal entry and then
+// empties the journal. A crash between the two steps is harmless because
+// the snapshot records the sequence number it covers.
+func (s *Store) compact() error {
+	if err := s.saveToFile(); err != nil {
+		return err
+	}
+	if err := s.archiveJournal(); err != nil {
+		return err
+	}
+	return s.journal.reset()
 }
 
 func (s *Store) saveToFile() error {
-	data := struct {
-		TodoLists []models.TodoList `json:"todo_lists"`
-		Users     []models.User     `json:"users"`
-	}{
-		TodoLists: s.todoLists,
-		Users:     s.users,
+	if s.filePath == "" {
+		return nil
 	}
 
-	file, err := os.Create(s.filePath)
+	data, err := json.MarshalIndent(s.snapshot(), "", "  ")
 	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
-	}
-	defer file.Close()
-
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(data); err != nil {
 		return fmt.Errorf("failed to encode JSON: %w", err)
 	}
 
-	return nil
+	return writeFileAtomic(s.filePath, append(data, '\n'))
 }
 
 func (s *Store) loadFromFile() error {
+	if s.filePath == "" {
+		return nil
+	}
+
 	file, err := os.Open(s.filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -207,16 +599,44 @@ func (s *Store) loadFromFile() error {
 	}
 	defer file.Close()
 
-	var data struct {
-		TodoLists []models.TodoList `json:"todo_lists"`
-		Users     []models.User     `json:"users"`
-	}
-
+	var data snapshot
 	if err := json.NewDecoder(file).Decode(&data); err != nil {
 		return fmt.Errorf("failed to decode JSON: %w", err)
 	}
 
-	s.todoLists = data.TodoLists
-	s.users = data.Users
+	if data.TodoLists != nil {
+		s.todoLists = data.TodoLists
+	}
+	if data.Users != nil {
+		s.users = data.Users
+	}
+	s.tokens = data.RefreshTokens
+	s.sessions = data.Sessions
+	s.apiKeys = data.APIKeys
+	s.auditLog = data.AuditLog
+	s.orgs = data.Organizations
+	s.seq = data.Seq
+	s.ids = ids{lastList: data.LastListID, lastItem: data.LastItemID, lastUser: data.LastUserID, lastKey: data.LastKeyID}
+	s.ids.observeAll(s.todoLists, s.users, s.apiKeys)
 	return nil
-} 
\ No newline at end of file
+}
+
+// snapshot returns the current state in its on-disk layout. The slices are
+// shared with the store, so the caller must hold the lock while using it.
+func (s *Store) snapshot() snapshot {
+	return snapshot{
+		Seq:        s.seq,
+		LastListID: s.ids.lastList,
+		LastItemID: s.ids.lastItem,
+		LastUserID: s.ids.lastUser,
+		LastKeyID:  s.ids.lastKey,
+		TodoLists:  s.todoLists,
+		Users:      s.users,
+
+		RefreshTokens: s.tokens,
+		Sessions:      s.sessions,
+		APIKeys:       s.apiKeys,
+		AuditLog:      s.auditLog,
+		Organizations: s.orgs,
+	}
+}
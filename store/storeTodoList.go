@@ -24,15 +24,10 @@ func LoadTodosFromFile(filePath string) ([]models.TodoList, error) {
 }
 
 func SaveTodosToFile(filePath string, todoLists []models.TodoList) error {
-	file, err := os.Create(filePath)
+	data, err := json.Marshal(todoLists)
 	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
-	}
-	defer file.Close()
-
-	if err := json.NewEncoder(file).Encode(todoLists); err != nil {
 		return fmt.Errorf("failed to encode JSON: %w", err)
 	}
 
-	return nil
+	return writeFileAtomic(filePath, data)
 }
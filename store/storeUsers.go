@@ -24,15 +24,10 @@ func LoadUsersFromFile(filePath string) ([]models.User, error) {
 }
 
 func SaveUsersToFile(filePath string, users []models.User) error {
-	file, err := os.Create(filePath)
+	data, err := json.Marshal(users)
 	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
-	}
-	defer file.Close()
-
-	if err := json.NewEncoder(file).Encode(users); err != nil {
 		return fmt.Errorf("failed to encode JSON: %w", err)
 	}
 
-	return nil
+	return writeFileAtomic(filePath, data)
 }
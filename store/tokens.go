@@ -0,0 +1,120 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/YahyaCengiz/todo-v2/models"
+)
+
+// Refresh tokens and sessions are kept with the rest of the data and
+// journaled the same way, but restoring a snapshot leaves them alone:
+// rolling back the data must not bring revoked sessions back to life.
+
+func (s *Store) CreateRefreshToken(token *models.RefreshToken) error {
+	return s.Tx(func(tx Repository) error { return tx.CreateRefreshToken(token) })
+}
+
+func (s *Store) GetRefreshToken(tokenHash string) (*models.RefreshToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.getRefreshToken(tokenHash)
+}
+
+func (s *Store) GetRefreshTokensByFamily(familyID string) ([]models.RefreshToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.getRefreshTokensByFamily(familyID), nil
+}
+
+func (s *Store) GetRefreshTokensByUser(userID int) ([]models.RefreshToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.getRefreshTokensByUser(userID), nil
+}
+
+func (s *Store) UpdateRefreshToken(token *models.RefreshToken) error {
+	return s.Tx(func(tx Repository) error { return tx.UpdateRefreshToken(token) })
+}
+
+func (s *Store) DeleteExpiredRefreshTokens(before time.Time) (int, error) {
+	var n int
+	err := s.Tx(func(tx Repository) error {
+		var err error
+		n, err = tx.DeleteExpiredRefreshTokens(before)
+		return err
+	})
+	return n, err
+}
+
+func (s *Store) getRefreshToken(tokenHash string) (*models.RefreshToken, error) {
+	if i := s.findToken(tokenHash); i >= 0 {
+		token := s.tokens[i]
+		return &token, nil
+	}
+	return nil, ErrRefreshTokenNotFound
+}
+
+func (s *Store) getRefreshTokensByFamily(familyID string) []models.RefreshToken {
+	hashes := s.idx.families[familyID]
+	tokens := make([]models.RefreshToken, 0, len(hashes))
+	for _, hash := range hashes {
+		tokens = append(tokens, s.tokens[s.idx.tokens[hash]])
+	}
+	return tokens
+}
+
+// getRefreshTokensByUser scans all tokens; it is only used for the rare
+// "log out everywhere" cases, so it does not get an index of its own.
+func (s *Store) getRefreshTokensByUser(userID int) []models.RefreshToken {
+	tokens := make([]models.RefreshToken, 0)
+	for _, token := range s.tokens {
+		if token.UserID == userID {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+func (tx *storeTx) CreateRefreshToken(token *models.RefreshToken) error {
+	if tx.s.findToken(token.TokenHash) >= 0 {
+		return fmt.Errorf("refresh token already exists")
+	}
+	created := *token
+	tx.apply(mutation{Op: opCreateToken, Token: &created})
+	return nil
+}
+
+func (tx *storeTx) GetRefreshToken(tokenHash string) (*models.RefreshToken, error) {
+	return tx.s.getRefreshToken(tokenHash)
+}
+
+func (tx *storeTx) GetRefreshTokensByFamily(familyID string) ([]models.RefreshToken, error) {
+	return tx.s.getRefreshTokensByFamily(familyID), nil
+}
+
+func (tx *storeTx) GetRefreshTokensByUser(userID int) ([]models.RefreshToken, error) {
+	return tx.s.getRefreshTokensByUser(userID), nil
+}
+
+func (tx *storeTx) UpdateRefreshToken(token *models.RefreshToken) error {
+	if tx.s.findToken(token.TokenHash) < 0 {
+		return ErrRefreshTokenNotFound
+	}
+	updated := *token
+	tx.apply(mutation{Op: opUpdateToken, Token: &updated})
+	return nil
+}
+
+func (tx *storeTx) DeleteExpiredRefreshTokens(before time.Time) (int, error) {
+	var expired []string
+	for _, token := range tx.s.tokens {
+		if token.ExpiresAt.Before(before) {
+			expired = append(expired, token.TokenHash)
+		}
+	}
+	for _, hash := range expired {
+		tx.apply(mutation{Op: opRemoveToken, Token: &models.RefreshToken{TokenHash: hash}})
+	}
+	return len(expired), nil
+}
@@ -0,0 +1,249 @@
+package store
+
+import (
+	"log"
+	"time"
+
+	"github.com/YahyaCengiz/todo-v2/models"
+)
+
+// Tx runs fn with exclusive access to the store. Every change fn makes
+// through tx is visible to its own reads immediately, is written to the
+// journal as a single entry when fn returns nil, and is undone when fn
+// returns an error or panics. fn must not call back into s itself.
+func (s *Store) Tx(fn func(tx Repository) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &storeTx{s: s}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		tx.rollback()
+		return err
+	}
+	return tx.commit()
+}
+
+// storeTx is the Repository handed to Tx callbacks. The caller already
+// holds the store lock, so its methods work on the store directly and keep
+// an undo log instead of copying the data up front.
+type storeTx struct {
+	s         *Store
+	mutations []mutation
+	undo      []mutation
+}
+
+var _ Repository = (*storeTx)(nil)
+
+func (tx *storeTx) apply(m mutation) {
+	tx.undo = append(tx.undo, tx.s.inverse(m))
+	tx.s.apply(m)
+	tx.mutations = append(tx.mutations, m)
+}
+
+func (tx *storeTx) rollback() {
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		tx.s.apply(tx.undo[i])
+	}
+	tx.mutations, tx.undo = nil, nil
+}
+
+func (tx *storeTx) commit() error {
+	if len(tx.mutations) == 0 {
+		return nil
+	}
+	s := tx.s
+	if s.journal != nil {
+		if err := s.journal.append(s.seq+1, tx.mutations); err != nil {
+			tx.rollback()
+			return err
+		}
+		s.seq++
+		// The change is durable once it is in the journal, so a failed
+		// compaction must not report it as failed; the journal simply
+		// grows until the next commit or startup compacts it.
+		if s.journal.n >= compactAfter {
+			if err := s.compact(); err != nil {
+				log.Printf("failed to compact the store journal: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// Tx inside a transaction joins the running one.
+func (tx *storeTx) Tx(fn func(tx Repository) error) error {
+	return fn(tx)
+}
+
+func (tx *storeTx) CreateTodoList(todoList *models.TodoList) error {
+	todoList.ID = tx.s.ids.nextList()
+	tx.apply(mutation{Op: opCreateList, List: copyList(todoList)})
+	return nil
+}
+
+func (tx *storeTx) GetTodoList(id int) (*models.TodoList, error) {
+	return tx.s.getTodoList(id)
+}
+
+func (tx *storeTx) GetTodoListsByOrg(orgID int) ([]*models.TodoList, error) {
+	return tx.s.getTodoListsByOrg(orgID), nil
+}
+
+func (tx *storeTx) GetTodoListsByUser(userID int) ([]*models.TodoList, error) {
+	return tx.s.getTodoListsByUser(userID), nil
+}
+
+func (tx *storeTx) GetTodoListsByMember(userID int) ([]*models.TodoList, error) {
+	return tx.s.getTodoListsByMember(userID), nil
+}
+
+func (tx *storeTx) UpdateTodoList(todoList *models.TodoList) error {
+	if tx.s.findList(todoList.ID) < 0 {
+		return ErrListNotFound
+	}
+	tx.apply(mutation{Op: opUpdateList, List: listFields(todoList)})
+	return nil
+}
+
+func (tx *storeTx) DeleteTodoList(id int) error {
+	i := tx.s.findList(id)
+	if i < 0 {
+		return ErrListNotFound
+	}
+	todoList := listFields(&tx.s.todoLists[i])
+	todoList.DeletedAt = time.Now()
+	tx.apply(mutation{Op: opUpdateList, List: todoList})
+	return nil
+}
+
+func (tx *storeTx) CreateTodoItem(todoItem *models.TodoItem) error {
+	i := tx.s.findList(todoItem.TodoListID)
+	if i < 0 {
+		return ErrListNotFound
+	}
+	todoItem.ID = tx.s.ids.nextItem()
+	tx.apply(mutation{Op: opCreateItem, Item: copyItem(todoItem)})
+	return nil
+}
+
+func (tx *storeTx) GetTodoItem(listID, itemID int) (*models.TodoItem, error) {
+	return tx.s.getTodoItem(listID, itemID)
+}
+
+func (tx *storeTx) UpdateTodoItem(listID int, todoItem *models.TodoItem) error {
+	if _, j := tx.s.findItem(listID, todoItem.ID); j < 0 {
+		return ErrItemNotFound
+	}
+	item := copyItem(todoItem)
+	item.TodoListID = listID
+	tx.apply(mutation{Op: opUpdateItem, Item: item})
+	return nil
+}
+
+func (tx *storeTx) DeleteTodoItem(listID, itemID int) error {
+	i, j := tx.s.findItem(listID, itemID)
+	if j < 0 {
+		return ErrItemNotFound
+	}
+	item := copyItem(&tx.s.todoLists[i].TodoItems[j])
+	item.DeletedAt = time.Now()
+	tx.apply(mutation{Op: opUpdateItem, Item: item})
+	return nil
+}
+
+func (tx *storeTx) PurgeDeleted(before time.Time) (*PurgeResult, error) {
+	result := &PurgeResult{ListIDs: []int{}, Items: []ItemRef{}}
+	purged := func(deletedAt time.Time) bool {
+		return !deletedAt.IsZero() && deletedAt.Before(before)
+	}
+
+	var removals []mutation
+	for _, todoList := range tx.s.todoLists {
+		if purged(todoList.DeletedAt) {
+			result.ListIDs = append(result.ListIDs, todoList.ID)
+			for _, item := range todoList.TodoItems {
+				result.Items = append(result.Items, ItemRef{ListID: todoList.ID, ItemID: item.ID})
+			}
+			removals = append(removals, mutation{Op: opRemoveList, List: &models.TodoList{ID: todoList.ID}})
+			continue
+		}
+		for _, item := range todoList.TodoItems {
+			if purged(item.DeletedAt) {
+				result.Items = append(result.Items, ItemRef{ListID: todoList.ID, ItemID: item.ID})
+				removals = append(removals, mutation{Op: opRemoveItem, Item: &models.TodoItem{TodoListID: todoList.ID, ID: item.ID}})
+			}
+		}
+	}
+
+	for _, m := range removals {
+		tx.apply(m)
+	}
+	return result, nil
+}
+
+func (tx *storeTx) GetUsers() ([]models.User, error) {
+	return copyUsers(tx.s.users), nil
+}
+
+func (tx *storeTx) GetUserByUsername(username string) (*models.User, error) {
+	return tx.s.getUserByUsername(username)
+}
+
+func (tx *storeTx) GetUserByID(id int) (*models.User, error) {
+	return tx.s.getUserByID(id)
+}
+
+func (tx *storeTx) GetUserByEmail(email string) (*models.User, error) {
+	return tx.s.getUserByEmail(email)
+}
+
+func (tx *storeTx) GetUserBySSOSubject(issuer, subject string) (*models.User, error) {
+	return tx.s.getUserBySSOSubject(issuer, subject)
+}
+
+func (tx *storeTx) AddUser(user *models.User) error {
+	if _, taken := tx.s.findUser(user.Username); taken {
+		return ErrUsernameTaken
+	}
+	if _, taken := tx.s.findUserByEmail(user.Email); taken {
+		return ErrEmailTaken
+	}
+	if _, taken := tx.s.findUserBySSOSubject(user.SSOIssuer, user.SSOSubject); taken {
+		return ErrSSOSubjectTaken
+	}
+	if user.ID == 0 {
+		user.ID = tx.s.ids.nextUser()
+	}
+	tx.apply(mutation{Op: opAddUser, User: copyUser(user)})
+	return nil
+}
+
+func (tx *storeTx) UpdateUser(user models.User) error {
+	if tx.s.findUserPos(user.ID, user.Username) < 0 {
+		return ErrUserNotFound
+	}
+	if other, taken := tx.s.findUserByEmail(user.Email); taken && other.ID != user.ID {
+		return ErrEmailTaken
+	}
+	if other, taken := tx.s.findUserBySSOSubject(user.SSOIssuer, user.SSOSubject); taken && other.ID != user.ID {
+		return ErrSSOSubjectTaken
+	}
+	tx.apply(mutation{Op: opUpdateUser, User: copyUser(&user)})
+	return nil
+}
+
+func (tx *storeTx) DeleteUser(id int) error {
+	user, ok := tx.s.findUserByID(id)
+	if !ok {
+		return ErrUserNotFound
+	}
+	tx.apply(mutation{Op: opRemoveUser, User: &models.User{ID: user.ID, Username: user.Username}})
+	return nil
+}
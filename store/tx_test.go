@@ -0,0 +1,171 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/YahyaCengiz/todo-v2/models"
+)
+
+var errAbort = errors.New("abort")
+
+// TestTxConcurrent runs writers and readers against one store at once; run
+// it with -race. Every writer transaction creates a list with two items,
+// and every third one fails halfway, so readers must never see a list with
+// fewer items, and the store must end up with exactly the committed lists,
+// also after closing and reopening it.
+func TestTxConcurrent(t *testing.T) {
+	const (
+		writers = 8
+		readers = 4
+		txs     = 30
+	)
+	path := filepath.Join(t.TempDir(), "store.json")
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		writersWG, readersWG sync.WaitGroup
+		mu                   sync.Mutex
+		committed            []int
+		done                 = make(chan struct{})
+	)
+	for w := 0; w < writers; w++ {
+		writersWG.Add(1)
+		go func(w int) {
+			defer writersWG.Done()
+			for n := 0; n < txs; n++ {
+				var listID int
+				err := s.Tx(func(tx Repository) error {
+					todoList := &models.TodoList{Name: "list", UserID: w + 1, OrgID: models.DefaultOrganizationID}
+					if err := tx.CreateTodoList(todoList); err != nil {
+						return err
+					}
+					listID = todoList.ID
+					if err := tx.CreateTodoItem(&models.TodoItem{TodoListID: listID, Content: "first"}); err != nil {
+						return err
+					}
+					if n%3 == 2 {
+						return errAbort
+					}
+					return tx.CreateTodoItem(&models.TodoItem{TodoListID: listID, Content: "second"})
+				})
+				switch {
+				case err == nil:
+					mu.Lock()
+					committed = append(committed, listID)
+					mu.Unlock()
+				case !errors.Is(err, errAbort):
+					t.Errorf("writer %d: %v", w, err)
+					return
+				}
+			}
+		}(w)
+	}
+	for r := 0; r < readers; r++ {
+		readersWG.Add(1)
+		go func(r int) {
+			defer readersWG.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				lists, err := s.GetTodoListsByOrg(models.DefaultOrganizationID)
+				if err != nil {
+					t.Errorf("reader %d: %v", r, err)
+					return
+				}
+				for _, todoList := range lists {
+					if len(todoList.TodoItems) != 2 {
+						t.Errorf("reader %d saw list %d with %d items", r, todoList.ID, len(todoList.TodoItems))
+						return
+					}
+					// Readers get copies, which the race detector checks.
+					todoList.TodoItems[0].Content = "changed"
+				}
+				if _, err := s.GetTodoListsByUser(r + 1); err != nil {
+					t.Errorf("reader %d: %v", r, err)
+					return
+				}
+			}
+		}(r)
+	}
+	writersWG.Wait()
+	close(done)
+	readersWG.Wait()
+
+	wantLists := writers * (txs - txs/3)
+	if len(committed) != wantLists {
+		t.Fatalf("%d transactions committed, want %d", len(committed), wantLists)
+	}
+	slices.Sort(committed)
+	check := func(s *Store) {
+		t.Helper()
+		lists, err := s.GetTodoListsByOrg(models.DefaultOrganizationID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]int, 0, len(lists))
+		for _, todoList := range lists {
+			ids = append(ids, todoList.ID)
+			if len(todoList.TodoItems) != 2 {
+				t.Errorf("list %d has %d items, want 2", todoList.ID, len(todoList.TodoItems))
+				continue
+			}
+			first, second := todoList.TodoItems[0], todoList.TodoItems[1]
+			if first.Content != "first" || second.Content != "second" {
+				t.Errorf("list %d has items %q and %q, want first and second", todoList.ID, first.Content, second.Content)
+			}
+			if first.ID == second.ID {
+				t.Errorf("list %d uses item ID %d twice", todoList.ID, first.ID)
+			}
+		}
+		slices.Sort(ids)
+		if !slices.Equal(ids, committed) {
+			t.Errorf("store has lists %v, want the committed %v", ids, committed)
+		}
+	}
+	check(s)
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	check(reopened)
+}
+
+// TestTxCommitSurvivesFailedCompaction makes compaction fail and checks
+// that the journaled transaction still counts as committed.
+func TestTxCommitSurvivesFailedCompaction(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileStore(filepath.Join(dir, "store.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.journal.close()
+	s.filePath = filepath.Join(dir, "missing", "store.json")
+
+	for n := 0; n < compactAfter; n++ {
+		todoList := &models.TodoList{Name: "list", UserID: 1, OrgID: models.DefaultOrganizationID}
+		if err := s.CreateTodoList(todoList); err != nil {
+			t.Fatalf("commit %d: %v", n+1, err)
+		}
+	}
+	if s.journal.n != compactAfter {
+		t.Errorf("journal has %d entries, want the %d that could not be compacted", s.journal.n, compactAfter)
+	}
+	if _, err := s.GetTodoList(compactAfter); err != nil {
+		t.Errorf("last list: %v", err)
+	}
+}